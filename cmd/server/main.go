@@ -4,11 +4,22 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"gerrit-mcp/internal/audit"
+	"gerrit-mcp/internal/certs"
+	"gerrit-mcp/internal/events"
+	gerritclient "gerrit-mcp/internal/gerrit"
 	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/metrics"
+	"gerrit-mcp/internal/middlewares"
+	"gerrit-mcp/internal/quota"
+	"gerrit-mcp/internal/tracing"
+	"gerrit-mcp/internal/watch"
 	"gerrit-mcp/pkg/mcp"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/andygrunwald/go-gerrit"
 )
@@ -19,23 +30,112 @@ const (
 	DEFAULT_GERRIT_INSTANCE  = "https://chromium-review.googlesource.com"
 	DEFAULT_AUTH_HEADER_NAME = "Authorization"
 	DEFAULT_USE_SSE          = false
+	DEFAULT_METRICS_PATH     = "/metrics"
+	CACHE_STATS_LOG_INTERVAL = 10 * time.Minute
+	// time left after the grace period for cancelled calls to return
+	SHUTDOWN_DRAIN_TIMEOUT = 10 * time.Second
+)
+
+// Exit codes
+const (
+	EXIT_OK                  = 0
+	EXIT_SERVER_ERROR        = 1
+	EXIT_SHUTDOWN_INCOMPLETE = 2
 )
 
 func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	// flushed last, after the audit log and the traces
+	defer logger.Sync()
+	config := mcp.Config{
+		AuthHeaderName: DEFAULT_AUTH_HEADER_NAME,
+		AuthSecret:     os.Getenv("BEARER_TOKEN"),
+	}
 	port := flag.String("port", DEFAULT_PORT, "Port to listen on")
 	addr := flag.String("addr", DEFAULT_HOST, "Address to listen on")
-	sse := flag.Bool("sse", DEFAULT_USE_SSE, "Use SSE instead of streamable HTTP")
-	gerritInstance := flag.String("gerrit-instance", DEFAULT_GERRIT_INSTANCE, "Gerrit instance URL")
+	configPath := flag.String("config", "", "YAML configuration file, flags given on the command line take precedence")
+	flag.BoolVar(&config.UseSSE, "sse", DEFAULT_USE_SSE, "Use SSE instead of streamable HTTP")
+	flag.StringVar(&config.GerritInstance, "gerrit-instance", DEFAULT_GERRIT_INSTANCE, "Gerrit instance URL")
 	withAuth := flag.String("with-auth", "", "Use authentication")
+	flag.StringVar(&config.JWKS, "jwks", "", "JWKS file path or URL used to validate JWT bearer tokens")
+	flag.StringVar(&config.JWTIssuer, "jwt-issuer", "", "Expected issuer of JWT bearer tokens, required with -jwks")
+	flag.StringVar(&config.JWTAudience, "jwt-audience", "", "Expected audience of JWT bearer tokens, required with -jwks")
+	flag.StringVar(&config.Audit.Output, "audit-log", "", "Audit log output: stdout or a file path (disabled when empty)")
+	flag.IntVar(&config.Audit.MaxSizeMB, "audit-max-size", audit.DefaultMaxSizeMB, "Size in MB after which the audit log file is rotated")
+	flag.IntVar(&config.Audit.MaxBackups, "audit-max-backups", 0, "Number of rotated audit log files to keep (0 keeps all)")
+	flag.IntVar(&config.Audit.MaxAgeDays, "audit-max-age", 0, "Days to keep rotated audit log files (0 keeps them forever)")
+	flag.StringVar(&config.APIKeysFile, "api-keys", "", "Keyfile of named API keys accepted as bearer tokens (reloaded on SIGHUP)")
+	flag.BoolVar(&config.Cache.Enabled, "cache", false, "Cache Gerrit REST responses")
+	flag.IntVar(&config.Cache.MaxSizeMB, "cache-size", gerritclient.DefaultCacheSizeMB, "Maximum size in MB of the in-memory Gerrit response cache")
+	flag.StringVar(&config.Cache.Dir, "cache-dir", "", "Directory of the optional on-disk Gerrit response cache")
+	flag.IntVar(&config.Cache.DiskMaxSizeMB, "cache-dir-size", gerritclient.DefaultDiskSizeMB, "Maximum size in MB of the on-disk Gerrit response cache, least recently used responses are pruned beyond it")
+	flag.DurationVar(&config.Cache.ProjectsTTL, "cache-projects-ttl", gerritclient.DefaultProjectsTTL, "How long project lists are cached")
+	flag.DurationVar(&config.Cache.ChangesTTL, "cache-changes-ttl", gerritclient.DefaultChangesTTL, "How long change metadata is cached")
+	flag.IntVar(&config.Quota.RequestsPerMinute, "quota-rpm", 0, "Tool calls, resource reads, prompts and completions per minute allowed to each caller (0 is unlimited)")
+	flag.IntVar(&config.Quota.MaxConcurrent, "quota-concurrent", 0, "Concurrent requests allowed to each caller (0 is unlimited)")
+	flag.IntVar(&config.Quota.DailyGerritCalls, "quota-daily-gerrit-calls", 0, "Gerrit REST calls allowed to each caller per UTC day (0 is unlimited)")
+	flag.StringVar(&config.MetricsPath, "metrics-path", DEFAULT_METRICS_PATH, "HTTP path of the Prometheus metrics (empty disables them)")
+	flag.StringVar(&config.TLS.CertFile, "tls-cert", "", "TLS certificate file, serves HTTPS when set (reloaded on change)")
+	flag.StringVar(&config.TLS.KeyFile, "tls-key", "", "TLS private key file")
+	flag.StringVar(&config.TLS.ClientCAFile, "tls-client-ca", "", "CA bundle verifying client certificates (enables mTLS)")
+	flag.StringVar(&config.TLS.ClientAuth, "tls-client-auth", certs.ClientAuthRequire, "Client certificates: require, or optional to also accept bearer tokens")
+	flag.BoolVar(&config.RedactSecrets, "redact-secrets", false, "Redact secrets (tokens, private keys, passwords) found in tool outputs")
+	flag.BoolVar(&config.ReadOnly, "read-only", false, "Only offer read-only tools, tools changing Gerrit are not registered")
+	flag.StringVar(&config.Log.Format, "log-format", logger.FormatConsole, "Log format: console or json")
+	flag.StringVar(&config.Log.Level, "log-level", "", "Log level: debug, info, warn or error (debug when DEBUG=true, info otherwise)")
+	flag.StringVar(&config.Log.Output, "log-output", "stdout", "Log output: stdout, stderr or a file path")
+	flag.DurationVar(&config.ShutdownGracePeriod, "shutdown-grace-period", mcp.DefaultShutdownGracePeriod, "How long in-flight tool calls may run on shutdown before they are cancelled")
+	flag.StringVar(&config.Events.Source, "events-source", "", "Source of Gerrit events: ssh, events-log or file (watched changes are polled when empty)")
+	flag.StringVar(&config.Events.SSHAddress, "events-ssh", "", "user@host[:port] of the Gerrit SSH daemon streaming events")
+	flag.StringVar(&config.Events.SSHKeyFile, "events-ssh-key", "", "Private key used to stream events over SSH")
+	flag.StringVar(&config.Events.File, "events-file", "", "File of stream-events JSON lines to replay")
+	flag.DurationVar(&config.Events.PollInterval, "events-poll-interval", events.DefaultPollInterval, "How often the events-log plugin is polled")
+	flag.StringVar(&config.Events.Webhook.Secret, "webhook-secret", "", "Secret authenticating the deliveries of the Gerrit webhooks plugin (the webhook endpoint is disabled when empty)")
+	flag.StringVar(&config.Events.Webhook.Path, "webhook-path", events.DefaultWebhookPath, "HTTP path receiving the deliveries of the Gerrit webhooks plugin")
+	flag.IntVar(&config.Events.BufferSize, "recent-events", events.DefaultBufferSize, "Number of recent Gerrit events kept for list_recent_events")
+	flag.DurationVar(&config.WatchPollInterval, "watch-poll-interval", watch.DefaultPollInterval, "How often watched changes are checked for updates")
+	flag.StringVar(&config.Tracing.Exporter, "trace-exporter", "", "OpenTelemetry trace exporter: otlp-grpc or otlp-http (disabled when empty)")
+	flag.StringVar(&config.Tracing.Endpoint, "trace-endpoint", "", "OTLP collector host:port (defaults to OTEL_EXPORTER_OTLP_* environment variables)")
+	flag.BoolVar(&config.Tracing.Insecure, "trace-insecure", false, "Export traces without TLS")
+	flag.Float64Var(&config.Tracing.SampleRatio, "trace-sample-ratio", 1, "Ratio of traces sampled when the caller did not decide")
 	flag.Parse()
+	if *configPath != "" {
+		if err := mcp.LoadConfigFile(*configPath, &config); err != nil {
+			logger.Fatalf("Failed to load configuration %s: %v", *configPath, err)
+		}
+		// parse again so that explicit flags override the file
+		flag.Parse()
+	}
+	if err := logger.Setup(config.Log); err != nil {
+		logger.Fatalf("Failed to set up logging: %v", err)
+	}
 	host := fmt.Sprintf("%s:%s", *addr, *port)
 	logger.Debugf("Starting Gerrit MCP server on %s", host)
-	logger.Debugf("Gerrit instance: %s", *gerritInstance)
+	logger.Debugf("Gerrit instance: %s", config.GerritInstance)
+	for name, instance := range config.Instances {
+		if err := instance.Tools.Validate(); err != nil {
+			logger.Fatalf("Gerrit instance %s: %v", name, err)
+		}
+	}
 
 	ctx := context.Background()
-	gerritClient, err := gerrit.NewClient(ctx, *gerritInstance, nil)
+	if config.Tracing.Exporter != "" {
+		shutdownTracing, err := tracing.Setup(ctx, config.Tracing, mcp.ServerVersion)
+		if err != nil {
+			logger.Fatalf("Failed to set up tracing: %v", err)
+		}
+		defer shutdownTracing(context.Background())
+		logger.Infof("Tracing enabled, exporting with %s", config.Tracing.Exporter)
+	}
+	gerritHTTPClient, err := newGerritHTTPClient(config)
+	if err != nil {
+		logger.Fatalf("Failed to set up Gerrit HTTP client: %v", err)
+	}
+	gerritClient, err := gerrit.NewClient(ctx, config.GerritInstance, gerritHTTPClient)
 	authMode := *withAuth
-	useSSE := *sse
 	switch authMode {
 	case "cookie":
 		cookieName := os.Getenv("GERRIT_COOKIE_NAME")
@@ -43,7 +143,7 @@ func main() {
 		if cookieName == "" || cookieValue == "" {
 			logger.Fatalf("GERRIT_COOKIE_NAME and GERRIT_COOKIE_VALUE must be set for cookie authentication")
 		}
-		logger.Infof("Authentication mode: %s with cookie %s", authMode, cookieName)
+		logger.Infof("Authentication mode: %s", authMode)
 		gerritClient.Authentication.SetCookieAuth(cookieName, cookieValue)
 	case "basic":
 		username := os.Getenv("GERRIT_USERNAME")
@@ -51,7 +151,7 @@ func main() {
 		if username == "" || password == "" {
 			logger.Fatalf("GERRIT_USERNAME and GERRIT_PASSWORD must be set for basic authentication")
 		}
-		logger.Infof("Authentication mode: %s with user %s", authMode, username)
+		logger.Infof("Authentication mode: %s", authMode)
 		gerritClient.Authentication.SetBasicAuth(username, password)
 	case "digest":
 		username := os.Getenv("GERRIT_USERNAME")
@@ -59,7 +159,7 @@ func main() {
 		if username == "" || password == "" {
 			logger.Fatalf("GERRIT_USERNAME and GERRIT_PASSWORD must be set for digest authentication")
 		}
-		logger.Infof("Authentication mode: %s with user %s", authMode, username)
+		logger.Infof("Authentication mode: %s", authMode)
 		gerritClient.Authentication.SetDigestAuth(username, password)
 	default:
 		logger.Infof("No authentication mode specified, using anonymous access")
@@ -67,16 +167,83 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Failed to create Gerrit client: %v", err)
 	}
-	mcpServer := mcp.NewServer(mcp.WithGerritClient(gerritClient),
-		mcp.WithConfig(mcp.Config{
-			AuthHeaderName: DEFAULT_AUTH_HEADER_NAME,
-			AuthSecret:     os.Getenv("BEARER_TOKEN"),
-			UseSSE:         useSSE,
-		}),
-	)
+	serverOpts := []mcp.ServerOption{mcp.WithGerritClient(gerritClient), mcp.WithConfig(config)}
+	if config.Quota.Enabled() {
+		logger.Infof("Per caller quota: %d calls/min, %d concurrent, %d Gerrit calls/day",
+			config.Quota.RequestsPerMinute, config.Quota.MaxConcurrent, config.Quota.DailyGerritCalls)
+		serverOpts = append(serverOpts, mcp.WithQuotaLimiter(quota.NewLimiter(config.Quota)))
+	}
+	if config.Audit.Output != "" {
+		auditLogger, err := audit.NewLoggerFromConfig(config.Audit)
+		if err != nil {
+			logger.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditLogger.Close()
+		logger.Infof("Audit log: %s", config.Audit.Output)
+		serverOpts = append(serverOpts, mcp.WithAuditLogger(auditLogger))
+	}
+	if config.Events.Source != "" {
+		reader, err := events.NewReader(config.Events, gerritClient)
+		if err != nil {
+			logger.Fatalf("Failed to set up the Gerrit event source: %v", err)
+		}
+		logger.Infof("Gerrit events read from %s", config.Events.Source)
+		serverOpts = append(serverOpts, mcp.WithEventReader(reader))
+	}
+	if config.JWKS != "" && config.APIKeysFile != "" {
+		logger.Fatalf("-jwks and -api-keys are mutually exclusive")
+	}
+	var apiKeyValidator *middlewares.APIKeyValidator
+	if config.APIKeysFile != "" {
+		apiKeyValidator, err = middlewares.NewAPIKeyValidator(config.AuthHeaderName, config.APIKeysFile)
+		if err != nil {
+			logger.Fatalf("Failed to load API keys: %v", err)
+		}
+		logger.Infof("MCP authentication mode: API keys (%s)", config.APIKeysFile)
+		serverOpts = append(serverOpts, mcp.WithTokenValidator(apiKeyValidator))
+	}
+	if config.JWKS != "" {
+		validator, err := middlewares.NewJWTTokenValidator(config.AuthHeaderName, config.JWKS, config.JWTIssuer, config.JWTAudience)
+		if err != nil {
+			logger.Fatalf("Failed to set up JWT validation: %v", err)
+		}
+		logger.Infof("MCP authentication mode: JWT (JWKS %s)", config.JWKS)
+		serverOpts = append(serverOpts, mcp.WithTokenValidator(validator))
+	}
+	if config.TLS.Enabled() {
+		reloader, err := certs.NewReloader(config.TLS)
+		if err != nil {
+			logger.Fatalf("Failed to set up TLS: %v", err)
+		}
+		go reloader.Watch(ctx)
+		serverOpts = append(serverOpts, mcp.WithTLSConfig(reloader.TLSConfig()))
+		logger.Infof("TLS enabled with certificate %s", config.TLS.CertFile)
+		if config.TLS.ClientCAFile != "" {
+			if len(config.ClientIdentities) == 0 {
+				logger.Infof("No ClientIdentities configured, every client certificate will be rejected")
+			}
+			logger.Infof("Client certificates verified against %s (%s)", config.TLS.ClientCAFile, config.TLS.ClientAuth)
+			serverOpts = append(serverOpts, mcp.WithCertificateValidator(middlewares.NewCertificateValidator(config.ClientIdentities)))
+		}
+	}
+	mcpServer := mcp.NewServer(serverOpts...)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	if apiKeyValidator != nil {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		go func() {
+			for range hupChan {
+				if err := apiKeyValidator.Reload(); err != nil {
+					logger.Errorf("Failed to reload API keys: %v", err)
+					continue
+				}
+				logger.Infof("Reloaded API keys from %s", config.APIKeysFile)
+			}
+		}()
+	}
+
 	// Start server in a goroutine
 	errChan := make(chan error, 1)
 	go func() {
@@ -87,9 +254,58 @@ func main() {
 	select {
 	case err := <-errChan:
 		if err != nil {
-			logger.Fatalf("Server error: %v", err)
+			logger.Errorf("Server error: %v", err)
+			return EXIT_SERVER_ERROR
 		}
+		return EXIT_OK
 	case sig := <-sigChan:
-		logger.Infof("Received signal: %v", sig)
+		logger.Infof("Received signal: %v, shutting down within %v", sig, config.ShutdownGracePeriod)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownGracePeriod+SHUTDOWN_DRAIN_TIMEOUT)
+	defer cancel()
+	go func() {
+		// a second signal skips the grace period
+		if _, ok := <-sigChan; ok {
+			logger.Errorf("Received second signal, cancelling in-flight tool calls")
+			cancel()
+		}
+	}()
+	if err := mcpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Errorf("Unclean shutdown: %v", err)
+		return EXIT_SHUTDOWN_INCOMPLETE
+	}
+	logger.Infof("Server stopped")
+	return EXIT_OK
+}
+
+// newGerritHTTPClient builds the HTTP client used for Gerrit REST calls.
+// Cached responses never reach the quota, policy and audit transports, every
+// retry is audited as a separate request but charged once to the caller quota.
+func newGerritHTTPClient(config mcp.Config) (*http.Client, error) {
+	var transport http.RoundTripper = audit.NewTransport(metrics.NewTransport(http.DefaultTransport))
+	transport = tracing.NewTransport(transport)
+	transport = gerritclient.NewPolicyTransport(transport, config.Instance(config.GerritInstance).Policy)
+	transport = quota.NewTransport(transport)
+	cacheConfig := config.Cache
+	if cacheConfig.Enabled {
+		cachingTransport, err := gerritclient.NewCachingTransport(transport, cacheConfig)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("Gerrit response cache enabled (%d MB in memory, disk: %q)", cacheConfig.MaxSizeMB, cacheConfig.Dir)
+		go func() {
+			for range time.Tick(CACHE_STATS_LOG_INTERVAL) {
+				logger.Infof("Gerrit response cache: %s", cachingTransport.Stats())
+			}
+		}()
+		err = metrics.RegisterCacheStats(func() (map[string]uint64, map[string]uint64) {
+			stats := cachingTransport.Stats()
+			return stats.Hits, stats.Misses
+		})
+		if err != nil {
+			return nil, err
+		}
+		transport = cachingTransport
 	}
+	return &http.Client{Transport: transport}, nil
 }
@@ -0,0 +1,160 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"gerrit-mcp/internal/redact"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveArguments are argument name fragments whose values are never written to the audit log.
+var sensitiveArguments = []string{"password", "secret", "token", "cookie", "credential", "key"}
+
+// Record is a single audit log entry, one per tool call, resource read,
+// prompt or completion request.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Caller  string    `json:"caller"`
+	Session string    `json:"session,omitempty"`
+	// Method is the MCP method, e.g. tools/call or resources/read.
+	Method      string         `json:"method"`
+	Tool        string         `json:"tool,omitempty"`
+	Resource    string         `json:"resource,omitempty"`
+	Prompt      string         `json:"prompt,omitempty"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+	GerritCalls []GerritCall   `json:"gerrit_calls,omitempty"`
+	Status      string         `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	LatencyMS   int64          `json:"latency_ms"`
+	Changes     []int          `json:"changes,omitempty"`
+}
+
+// GerritCall is a Gerrit REST request issued while handling an MCP request.
+type GerritCall struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Logger writes records as JSON lines to a sink.
+type Logger struct {
+	mu   sync.Mutex
+	sink io.WriteCloser
+	enc  *json.Encoder
+}
+
+func NewLogger(sink io.WriteCloser) *Logger {
+	return &Logger{sink: sink, enc: json.NewEncoder(sink)}
+}
+
+// NewLoggerFromConfig opens the sink described by cfg: "stdout" or a file path
+// rotated according to the retention policy.
+func NewLoggerFromConfig(cfg Config) (*Logger, error) {
+	if cfg.Output == "stdout" {
+		return NewLogger(nopCloser{os.Stdout}), nil
+	}
+	file, err := NewRotatingFile(cfg.Output, cfg.MaxSizeMB, cfg.MaxBackups, cfg.MaxAgeDays)
+	if err != nil {
+		return nil, err
+	}
+	return NewLogger(file), nil
+}
+
+func (l *Logger) Log(record Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(record)
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sink.Close()
+}
+
+// RedactArguments returns a copy of args with the values of sensitive
+// arguments replaced and the secrets found in string values redacted.
+func RedactArguments(args map[string]any) map[string]any {
+	if args == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(args))
+	for name, value := range args {
+		redacted[name] = value
+		if str, ok := value.(string); ok {
+			redacted[name] = redact.String(str)
+		}
+		lowerName := strings.ToLower(name)
+		for _, sensitive := range sensitiveArguments {
+			if strings.Contains(lowerName, sensitive) {
+				redacted[name] = redactedValue
+				break
+			}
+		}
+	}
+	return redacted
+}
+
+type Config struct {
+	// Output is "stdout" or a file path, empty disables auditing.
+	Output     string `yaml:"Output"`
+	MaxSizeMB  int    `yaml:"MaxSizeMB"`
+	MaxBackups int    `yaml:"MaxBackups"`
+	MaxAgeDays int    `yaml:"MaxAgeDays"`
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+type collectorKey struct{}
+
+// Collector gathers what happened while an MCP request was handled.
+type Collector struct {
+	mu          sync.Mutex
+	gerritCalls []GerritCall
+	changes     []int
+}
+
+func WithCollector(ctx context.Context) (context.Context, *Collector) {
+	c := &Collector{}
+	return context.WithValue(ctx, collectorKey{}, c), c
+}
+
+func collectorFromContext(ctx context.Context) *Collector {
+	c, _ := ctx.Value(collectorKey{}).(*Collector)
+	return c
+}
+
+// AddChanges records the numbers of the changes a request returned or modified.
+func AddChanges(ctx context.Context, numbers ...int) {
+	if c := collectorFromContext(ctx); c != nil {
+		c.mu.Lock()
+		c.changes = append(c.changes, numbers...)
+		c.mu.Unlock()
+	}
+}
+
+func (c *Collector) addGerritCall(call GerritCall) {
+	c.mu.Lock()
+	c.gerritCalls = append(c.gerritCalls, call)
+	c.mu.Unlock()
+}
+
+// Fill copies the collected Gerrit calls and change numbers into record.
+func (c *Collector) Fill(record *Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record.GerritCalls = append([]GerritCall(nil), c.gerritCalls...)
+	record.Changes = append([]int(nil), c.changes...)
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"net/http"
+	"time"
+)
+
+// Transport records every Gerrit REST request into the audit collector of
+// the request context.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	collector := collectorFromContext(req.Context())
+	if collector == nil {
+		return t.Base.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	call := GerritCall{
+		Method:    req.Method,
+		Path:      req.URL.EscapedPath(),
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Status = resp.StatusCode
+	}
+	collector.addGerritCall(call)
+	return resp, err
+}
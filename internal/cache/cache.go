@@ -0,0 +1,302 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store is a byte cache. A zero ttl stores the value until it is evicted.
+type Store interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// MemoryStats describes the content of a Memory store.
+type MemoryStats struct {
+	Entries   int
+	SizeBytes int64
+	Evictions uint64
+}
+
+// Memory is a LRU store bounded by the total size of its values.
+type Memory struct {
+	maxBytes int64
+
+	mu        sync.Mutex
+	size      int64
+	evictions uint64
+	order     *list.List
+	items     map[string]*list.Element
+}
+
+func NewMemory(maxBytes int64) *Memory {
+	return &Memory{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if e.expired(time.Now()) {
+		m.remove(elem)
+		return nil, false
+	}
+	m.order.MoveToFront(elem)
+	return e.value, true
+}
+
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) {
+	if int64(len(value)) > m.maxBytes {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	m.items[key] = m.order.PushFront(&entry{key: key, value: value, expires: expiry(ttl)})
+	m.size += int64(len(value))
+	for m.size > m.maxBytes {
+		m.remove(m.order.Back())
+		m.evictions++
+	}
+}
+
+func (m *Memory) remove(elem *list.Element) {
+	e := m.order.Remove(elem).(*entry)
+	delete(m.items, e.key)
+	m.size -= int64(len(e.value))
+}
+
+func (m *Memory) Stats() MemoryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MemoryStats{Entries: len(m.items), SizeBytes: m.size, Evictions: m.evictions}
+}
+
+// Disk stores every value in its own file under dir. Each file starts with
+// the expiry time of the value. Once the files exceed maxBytes, the least
+// recently used ones, by modification time, are pruned.
+type Disk struct {
+	dir      string
+	maxBytes int64
+
+	mu   sync.Mutex
+	size int64
+}
+
+func NewDisk(dir string, maxBytes int64) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	d := &Disk{dir: dir, maxBytes: maxBytes}
+	files, err := d.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		d.size += file.size
+	}
+	d.prune()
+	return d, nil
+}
+
+type diskFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (d *Disk) files() ([]diskFile, error) {
+	var files []diskFile
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// removed concurrently
+			return nil
+		}
+		files = append(files, diskFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// prune removes the least recently used files until the store is back under
+// 90% of maxBytes, leaving room for the next values before pruning again.
+func (d *Disk) prune() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.maxBytes <= 0 || d.size <= d.maxBytes {
+		return
+	}
+	files, err := d.files()
+	if err != nil {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	d.size = 0
+	for _, file := range files {
+		d.size += file.size
+	}
+	target := d.maxBytes / 10 * 9
+	for _, file := range files {
+		if d.size <= target {
+			break
+		}
+		if err := os.Remove(file.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			d.size -= file.size
+		}
+	}
+}
+
+func (d *Disk) remove(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if os.Remove(path) == nil {
+		d.mu.Lock()
+		d.size -= info.Size()
+		d.mu.Unlock()
+	}
+}
+
+func (d *Disk) path(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(digest[:])
+	return filepath.Join(d.dir, name[:2], name)
+}
+
+func (d *Disk) Get(key string) ([]byte, bool) {
+	value, _, ok := d.lookup(key)
+	return value, ok
+}
+
+func (d *Disk) lookup(key string) ([]byte, time.Time, bool) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) < 8 {
+		return nil, time.Time{}, false
+	}
+	var expires time.Time
+	if nanos := int64(binary.BigEndian.Uint64(data[:8])); nanos != 0 {
+		expires = time.Unix(0, nanos)
+		if time.Now().After(expires) {
+			d.remove(path)
+			return nil, time.Time{}, false
+		}
+	}
+	// the modification time orders the files for pruning
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data[8:], expires, true
+}
+
+func (d *Disk) Set(key string, value []byte, ttl time.Duration) {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return
+	}
+	data := make([]byte, 8+len(value))
+	if expires := expiry(ttl); !expires.IsZero() {
+		binary.BigEndian.PutUint64(data[:8], uint64(expires.UnixNano()))
+	}
+	copy(data[8:], value)
+	// write to a temporary file first so readers never see a partial value
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	var previous int64
+	if info, err := os.Stat(path); err == nil {
+		previous = info.Size()
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	d.mu.Lock()
+	d.size += int64(len(data)) - previous
+	d.mu.Unlock()
+	d.prune()
+}
+
+// Tiered looks values up in a Memory store first and falls back to a Disk
+// store, promoting the values found there.
+type Tiered struct {
+	Memory *Memory
+	Disk   *Disk
+}
+
+func (t *Tiered) Get(key string) ([]byte, bool) {
+	if value, ok := t.Memory.Get(key); ok {
+		return value, true
+	}
+	if t.Disk == nil {
+		return nil, false
+	}
+	value, expires, ok := t.Disk.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	var ttl time.Duration
+	if !expires.IsZero() {
+		if ttl = time.Until(expires); ttl <= 0 {
+			return nil, false
+		}
+	}
+	t.Memory.Set(key, value, ttl)
+	return value, true
+}
+
+func (t *Tiered) Set(key string, value []byte, ttl time.Duration) {
+	t.Memory.Set(key, value, ttl)
+	if t.Disk != nil {
+		t.Disk.Set(key, value, ttl)
+	}
+}
@@ -0,0 +1,196 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ClientAuthRequire  = "require"
+	ClientAuthOptional = "optional"
+
+	DefaultReloadInterval = 30 * time.Second
+)
+
+// Config of the TLS listener. TLS is enabled when CertFile is set, client
+// certificates are verified when ClientCAFile is set.
+type Config struct {
+	CertFile     string `yaml:"CertFile"`
+	KeyFile      string `yaml:"KeyFile"`
+	ClientCAFile string `yaml:"ClientCAFile"`
+	// ClientAuth is "require" (the default) or "optional", optional lets
+	// clients without a certificate authenticate with a bearer token.
+	ClientAuth string `yaml:"ClientAuth"`
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval time.Duration `yaml:"ReloadInterval"`
+}
+
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+func (c Config) clientAuthType() (tls.ClientAuthType, error) {
+	if c.ClientCAFile == "" {
+		return tls.NoClientCert, nil
+	}
+	switch c.ClientAuth {
+	case "", ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q", c.ClientAuth)
+}
+
+// Reloader serves the certificate, key and client CA bundle of a Config and
+// reloads them when the files change, so that renewed certificates are used
+// without a restart.
+type Reloader struct {
+	config     Config
+	clientAuth tls.ClientAuthType
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+func NewReloader(cfg Config) (*Reloader, error) {
+	if cfg.KeyFile == "" {
+		return nil, fmt.Errorf("a key file is required with the certificate %s", cfg.CertFile)
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = DefaultReloadInterval
+	}
+	clientAuth, err := cfg.clientAuthType()
+	if err != nil {
+		return nil, err
+	}
+	r := &Reloader{config: cfg, clientAuth: clientAuth}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previously loaded ones are kept.
+func (r *Reloader) Reload() error {
+	modTimes := make(map[string]time.Time, 3)
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load %s: %w", r.config.CertFile, err)
+	}
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		data, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate found in %s", r.config.ClientCAFile)
+		}
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+// changed reports whether a file was modified since the last reload.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			// the file may be in the middle of being replaced
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch reloads the files whenever they change until ctx is done.
+func (r *Reloader) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !r.changed() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			logger.Errorf("Failed to reload TLS certificates, keeping the previous ones: %v", err)
+			continue
+		}
+		logger.Infof("Reloaded TLS certificate %s", r.config.CertFile)
+	}
+}
+
+// TLSConfig returns a server configuration always using the last loaded
+// certificate and client CAs.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: r.clientAuth,
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.Certificates = []tls.Certificate{*r.cert}
+		config.ClientCAs = r.clientCAs
+		return config, nil
+	}
+	return base
+}
+
+// Identity returns the name identifying a client certificate: its common
+// name, or else its first URI, DNS or email subject alternative name.
+func Identity(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses[0]
+	}
+	return cert.Subject.String()
+}
@@ -14,6 +14,7 @@ import (
 )
 
 const (
+	// FileDiffsLimit bounds the files of a change whose diffs are kept
 	FileDiffsLimit = 3 
 )
 
@@ -34,29 +35,20 @@ type GerritChange struct {
 func NewGerritChange(changeInfo *gerrit.ChangeInfo, diffsInfo []*gerrit.DiffInfo, endpointURL string) (GerritChange, error) {
 	fpaths := make([]string, 0)
 	diffMap := make(map[string]string, 0)
-	for index, diffInfo := range diffsInfo {
-		content := diffInfo.Content
+	for _, diffInfo := range diffsInfo {
 		switch diffInfo.ChangeType {
-		case "ADDED":
-			fpaths = append(fpaths, diffInfo.MetaB.Name)
-			if index > FileDiffsLimit {
+		case "ADDED", "MODIFIED", "RENAMED", "COPIED":
+			// files are known by their new path, in Paths as in DiffMap
+			path := diffInfo.MetaB.Name
+			fpaths = append(fpaths, path)
+			if len(diffMap) >= FileDiffsLimit {
 				continue
 			}
 			var buf bytes.Buffer
-			for _, data := range content {
+			for _, data := range diffInfo.Content {
 				buf.WriteString(strings.Join(data.B, "\r\n"))
 			}
-			diffMap[diffInfo.MetaB.Name] = buf.String()
-		case "MODIFIED":
-			if index > FileDiffsLimit {
-				continue
-			}
-			fpaths = append(fpaths, diffInfo.MetaA.Name)
-			var buf bytes.Buffer
-			for _, data := range content {
-				buf.WriteString(strings.Join(data.B, "\r\n"))
-			}
-			diffMap[diffInfo.MetaB.Name] = buf.String()
+			diffMap[path] = buf.String()
 		}
 	}
 
@@ -73,25 +65,26 @@ func NewGerritChange(changeInfo *gerrit.ChangeInfo, diffsInfo []*gerrit.DiffInfo
 }
 
 func BuildGerritChanges(ctx context.Context, gerritClient *gerrit.Client, changes *[]gerrit.ChangeInfo) ([]GerritChange, error) {
+	log := logger.FromContext(ctx)
 	gerritChanges := make([]GerritChange, 0)
 	for _, curChange := range *changes {
-		logger.Debugf("processing %s %s", curChange.ID, curChange.Subject)
+		log.Debugf("processing %s %s", curChange.ID, curChange.Subject)
 		revision := curChange.CurrentRevision
 		if revision == "" {
 			revision = "current"
 		}
 		unfilteredFiles, _, rerr := gerritClient.Changes.ListFiles(ctx, curChange.ID, revision, &gerrit.FilesOptions{})
 		if rerr != nil {
-			logger.Errorf("%v", rerr)
+			log.Errorf("%v", rerr)
 			continue
 		}
 		files := util.FilterFiles(unfilteredFiles)
-		logger.Debugf("filtered files count %d\n", len(files))
+		log.Debugf("filtered files count %d\n", len(files))
 		// TODO: move to ShouldSkipChange
 		if len(files) > 32 || len(files) == 0 {
 			continue
 		}
-		logger.Debugf("moving with %s\n", strings.Join(files, "\n"))
+		log.Debugf("moving with %s\n", strings.Join(files, "\n"))
 		diffs := make([]*gerrit.DiffInfo, 0)
 		for _, fname := range files {
 			if fname == "/COMMIT_MSG" || fname == "/MERGE_LIST" || fname == "/PATCHSET_LEVEL" {
@@ -99,14 +92,15 @@ func BuildGerritChanges(ctx context.Context, gerritClient *gerrit.Client, change
 			}
 			diffInfo, _, diffErr := gerritClient.Changes.GetDiff(ctx, curChange.ID, revision, fname, nil)
 			if diffErr != nil {
-				logger.Errorf("%v", diffErr)
+				log.Errorf("unable to get diff of %s in %s: %v", fname, curChange.ID, diffErr)
+				continue
 			}
 			diffs = append(diffs, diffInfo)
 		}
 		u := gerritClient.BaseURL()
 		gerritChange, err := NewGerritChange(&curChange, diffs, u.String())
 		if err != nil {
-			logger.Errorf("%v", err)
+			log.Errorf("%v", err)
 		}
 		gerritChanges = append(gerritChanges, gerritChange)
 	}
@@ -126,7 +120,7 @@ func extractChangeId(rawChangeId string, endpointURL string) string {
 }
 
 // example: https://chromium-review.googlesource.com/c/chromium/src/+/4640000
-func BuildQueryFromURL(reviewURL string) (string, error) {
+func BuildQueryFromURL(ctx context.Context, reviewURL string) (string, error) {
 	// opt := &gerrit.QueryChangeOptions{}
 	u, err := url.Parse(reviewURL)
 	if err != nil {
@@ -134,18 +128,18 @@ func BuildQueryFromURL(reviewURL string) (string, error) {
 	}
 	path := u.EscapedPath()
 	parts := strings.Split(path, "/")
-	if len(parts) < 2 {
+	if len(parts) <= 2 || parts[2] == "" {
 		return "", fmt.Errorf("invalid review URL: %s (parts: %v)", reviewURL, parts)
 	}
 	urlType := parts[1]
 	switch urlType {
 	case "q":
-		logger.Debugf("query change URL: %s", reviewURL)
+		logger.FromContext(ctx).Debugf("query change URL: %s", reviewURL)
 		changeID := parts[2]
 		return fmt.Sprintf("change:%s", changeID), nil
 		// opt.Query = []string{fmt.Sprintf("change:%s", changeID)}
 	case "c":
-		if len(parts) < 5 {
+		if len(parts) <= 5 {
 			return "", fmt.Errorf("invalid review URL: %s (parts: %v)", reviewURL, parts)
 		}
 		if parts[1] != "c" || parts[4] != "+" {
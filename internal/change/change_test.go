@@ -0,0 +1,78 @@
+package change
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/andygrunwald/go-gerrit"
+)
+
+func TestBuildQueryFromURL(t *testing.T) {
+	tests := []struct {
+		reviewURL string
+		want      string
+		wantErr   bool
+	}{
+		{reviewURL: "https://chromium-review.googlesource.com/c/chromium/src/+/4640000", want: "change:4640000"},
+		{reviewURL: "https://chromium-review.googlesource.com/c/v8/v8/+/123/4", want: "change:123"},
+		{reviewURL: "https://chromium-review.googlesource.com/q/I8473b95934b5732ac55d26311a706c9c2bde9940", want: "change:I8473b95934b5732ac55d26311a706c9c2bde9940"},
+		{reviewURL: "https://host", wantErr: true},
+		{reviewURL: "https://host/q", wantErr: true},
+		{reviewURL: "https://host/q/", wantErr: true},
+		{reviewURL: "https://host/c/p/+", wantErr: true},
+		{reviewURL: "https://host/c/chromium/src/+", wantErr: true},
+		{reviewURL: "https://host/c/chromium/src/-/123", wantErr: true},
+		{reviewURL: "https://host/c/chromium/src/+/abc", wantErr: true},
+		{reviewURL: "https://host/dashboard/self", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := BuildQueryFromURL(context.Background(), tt.reviewURL)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("BuildQueryFromURL(%q) = %q, want an error", tt.reviewURL, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("BuildQueryFromURL(%q) = %q, %v, want %q", tt.reviewURL, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewGerritChange(t *testing.T) {
+	diff := func(changeType, oldPath, newPath string) *gerrit.DiffInfo {
+		return &gerrit.DiffInfo{
+			ChangeType: changeType,
+			MetaA:      gerrit.DiffFileMetaInfo{Name: oldPath},
+			MetaB:      gerrit.DiffFileMetaInfo{Name: newPath},
+			Content:    []gerrit.DiffContent{{B: []string{"+" + newPath}}},
+		}
+	}
+	diffs := []*gerrit.DiffInfo{
+		diff("RENAMED", "src/old_parser.cc", "src/parser.cc"),
+		diff("DELETED", "src/gone.cc", ""),
+		diff("MODIFIED", "src/main.cc", "src/main.cc"),
+	}
+	for i := range FileDiffsLimit {
+		diffs = append(diffs, diff("ADDED", "", fmt.Sprintf("src/new%d.cc", i)))
+	}
+	c, err := NewGerritChange(&gerrit.ChangeInfo{Subject: "Rename the parser"}, diffs, "https://gerrit.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantPaths := []string{"src/parser.cc", "src/main.cc", "src/new0.cc", "src/new1.cc", "src/new2.cc"}
+	if !slices.Equal(c.Paths, wantPaths) {
+		t.Errorf("Paths = %v, want %v", c.Paths, wantPaths)
+	}
+	if len(c.DiffMap) != FileDiffsLimit {
+		t.Errorf("DiffMap has %d diffs, want %d", len(c.DiffMap), FileDiffsLimit)
+	}
+	for _, path := range wantPaths[:FileDiffsLimit] {
+		if got := c.DiffMap[path]; got != "+"+path {
+			t.Errorf("DiffMap[%s] = %q, want the diff of the file", path, got)
+		}
+	}
+}
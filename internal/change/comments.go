@@ -0,0 +1,117 @@
+package change
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andygrunwald/go-gerrit"
+)
+
+// CommentThread is a published inline comment with its replies.
+type CommentThread struct {
+	Path       string
+	Line       int
+	PatchSet   int
+	Unresolved bool
+	Comments   []gerrit.CommentInfo
+}
+
+// FetchChange returns the change pointed to by a review URL, with its
+// current revision and its messages.
+func FetchChange(ctx context.Context, gerritClient *gerrit.Client, reviewURL string) (*gerrit.ChangeInfo, error) {
+	query, err := BuildQueryFromURL(ctx, reviewURL)
+	if err != nil {
+		return nil, err
+	}
+	opt := &gerrit.QueryChangeOptions{}
+	opt.Query = []string{query}
+	opt.AdditionalFields = []string{"CURRENT_REVISION", "MESSAGES"}
+	changes, _, err := gerritClient.Changes.QueryChanges(ctx, opt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", reviewURL, err)
+	}
+	if len(*changes) == 0 {
+		return nil, fmt.Errorf("no change found for %s", reviewURL)
+	}
+	return &(*changes)[0], nil
+}
+
+// BuildCommentThreads groups the published comments of a change into
+// threads, ordered by file and line.
+func BuildCommentThreads(ctx context.Context, gerritClient *gerrit.Client, changeID string) ([]CommentThread, error) {
+	comments, _, err := gerritClient.Changes.ListChangeComments(ctx, changeID)
+	if err != nil {
+		return nil, err
+	}
+	threads := make([]CommentThread, 0)
+	for path, fileComments := range *comments {
+		sort.SliceStable(fileComments, func(i, j int) bool {
+			return commentTime(fileComments[i]) < commentTime(fileComments[j])
+		})
+		// comments whose parent is unknown start their own thread
+		byID := make(map[string]int, len(fileComments))
+		for _, comment := range fileComments {
+			if index, ok := byID[comment.InReplyTo]; ok && comment.InReplyTo != "" {
+				threads[index].Comments = append(threads[index].Comments, comment)
+				byID[comment.ID] = index
+				continue
+			}
+			byID[comment.ID] = len(threads)
+			threads = append(threads, CommentThread{Path: path, Line: comment.Line, PatchSet: comment.PatchSet, Comments: []gerrit.CommentInfo{comment}})
+		}
+	}
+	for i := range threads {
+		last := threads[i].Comments[len(threads[i].Comments)-1]
+		threads[i].Unresolved = last.Unresolved != nil && *last.Unresolved
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].Path != threads[j].Path {
+			return threads[i].Path < threads[j].Path
+		}
+		return threads[i].Line < threads[j].Line
+	})
+	return threads, nil
+}
+
+func commentTime(comment gerrit.CommentInfo) int64 {
+	if comment.Updated == nil {
+		return 0
+	}
+	return comment.Updated.UnixNano()
+}
+
+// CommentThreadsText renders threads for a language model.
+func CommentThreadsText(threads []CommentThread) string {
+	if len(threads) == 0 {
+		return "No inline comments.\n"
+	}
+	builder := strings.Builder{}
+	for _, thread := range threads {
+		state := "resolved"
+		if thread.Unresolved {
+			state = "unresolved"
+		}
+		location := thread.Path
+		if thread.Line > 0 {
+			location = fmt.Sprintf("%s:%d", thread.Path, thread.Line)
+		}
+		if thread.PatchSet > 0 {
+			location = fmt.Sprintf("%s, patch set %d", location, thread.PatchSet)
+		}
+		builder.WriteString(fmt.Sprintf("%s (%s):\n", location, state))
+		for _, comment := range thread.Comments {
+			builder.WriteString(fmt.Sprintf("  %s: %s\n", comment.Author.Name, strings.ReplaceAll(comment.Message, "\n", "\n    ")))
+		}
+	}
+	return builder.String()
+}
+
+// MessagesText renders the change messages (votes and review summaries).
+func (c *GerritChange) MessagesText() string {
+	if len(c.Messages) == 0 {
+		return "No change messages.\n"
+	}
+	return strings.Join(c.Messages, "\n") + "\n"
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Types of the Gerrit events, as named by stream-events.
+const (
+	TypePatchSetCreated       = "patchset-created"
+	TypeCommentAdded          = "comment-added"
+	TypeChangeMerged          = "change-merged"
+	TypeChangeAbandoned       = "change-abandoned"
+	TypeChangeRestored        = "change-restored"
+	TypeReviewerAdded         = "reviewer-added"
+	TypeReviewerDeleted       = "reviewer-deleted"
+	TypeVoteDeleted           = "vote-deleted"
+	TypeTopicChanged          = "topic-changed"
+	TypeHashtagsChanged       = "hashtags-changed"
+	TypeWorkInProgressChanged = "wip-state-changed"
+	TypePrivateStateChanged   = "private-state-changed"
+	TypeRefUpdated            = "ref-updated"
+)
+
+// Event is a Gerrit event normalized from the stream-events format, which
+// is also the payload of the webhooks and events-log plugins.
+type Event struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Project string    `json:"project,omitempty"`
+	Branch  string    `json:"branch,omitempty"`
+	// Change is the change number, zero for events not about a change
+	Change   int    `json:"change,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	URL      string `json:"url,omitempty"`
+	PatchSet int    `json:"patchSet,omitempty"`
+	// Actor is the account causing the event: the uploader, the author of
+	// a comment, the submitter...
+	Actor   string `json:"actor,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	// Approvals maps the labels voted on by a comment to their value
+	Approvals map[string]string `json:"approvals,omitempty"`
+	// Ref is the updated ref of ref-updated events
+	Ref string `json:"ref,omitempty"`
+}
+
+type account struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+func (a *account) String() string {
+	switch {
+	case a == nil:
+		return ""
+	case a.Name != "" && a.Email != "":
+		return fmt.Sprintf("%s <%s>", a.Name, a.Email)
+	case a.Name != "":
+		return a.Name
+	case a.Email != "":
+		return a.Email
+	}
+	return a.Username
+}
+
+// rawEvent holds the fields of the stream-events JSON used by Event.
+type rawEvent struct {
+	Type           string `json:"type"`
+	EventCreatedOn int64  `json:"eventCreatedOn"`
+	Change         *struct {
+		Project string `json:"project"`
+		Branch  string `json:"branch"`
+		Number  int    `json:"number"`
+		Subject string `json:"subject"`
+		URL     string `json:"url"`
+	} `json:"change"`
+	PatchSet *struct {
+		Number int `json:"number"`
+	} `json:"patchSet"`
+	Uploader  *account `json:"uploader"`
+	Author    *account `json:"author"`
+	Submitter *account `json:"submitter"`
+	Abandoner *account `json:"abandoner"`
+	Restorer  *account `json:"restorer"`
+	Changer   *account `json:"changer"`
+	Editor    *account `json:"editor"`
+	Remover   *account `json:"remover"`
+	Adder     *account `json:"adder"`
+	Comment   string   `json:"comment"`
+	Reason    string   `json:"reason"`
+	Approvals []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"approvals"`
+	RefUpdate *struct {
+		Project string `json:"project"`
+		RefName string `json:"refName"`
+	} `json:"refUpdate"`
+}
+
+// Parse normalizes a Gerrit event in the stream-events JSON format.
+func Parse(data []byte) (Event, error) {
+	var raw rawEvent
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Event{}, fmt.Errorf("invalid event: %w", err)
+	}
+	if raw.Type == "" {
+		return Event{}, fmt.Errorf("invalid event: no type")
+	}
+	event := Event{
+		Type:    raw.Type,
+		Time:    time.Unix(raw.EventCreatedOn, 0).UTC(),
+		Comment: raw.Comment,
+	}
+	if raw.EventCreatedOn == 0 {
+		event.Time = time.Now().UTC().Truncate(time.Second)
+	}
+	if raw.Reason != "" {
+		event.Comment = raw.Reason
+	}
+	if raw.Change != nil {
+		event.Project = raw.Change.Project
+		event.Branch = raw.Change.Branch
+		event.Change = raw.Change.Number
+		event.Subject = raw.Change.Subject
+		event.URL = raw.Change.URL
+	}
+	if raw.PatchSet != nil {
+		event.PatchSet = raw.PatchSet.Number
+	}
+	if raw.RefUpdate != nil {
+		event.Project = raw.RefUpdate.Project
+		event.Ref = raw.RefUpdate.RefName
+	}
+	for _, actor := range []*account{raw.Uploader, raw.Author, raw.Submitter, raw.Abandoner, raw.Restorer, raw.Changer, raw.Editor, raw.Remover, raw.Adder} {
+		if actor != nil {
+			event.Actor = actor.String()
+			break
+		}
+	}
+	if len(raw.Approvals) > 0 {
+		event.Approvals = make(map[string]string, len(raw.Approvals))
+		for _, approval := range raw.Approvals {
+			event.Approvals[approval.Type] = approval.Value
+		}
+	}
+	return event, nil
+}
+
+// Handler consumes events, it must not block.
+type Handler func(Event)
+
+// Bus fans events out to its handlers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,292 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/metrics"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-gerrit"
+)
+
+const (
+	SourceSSH       = "ssh"
+	SourceEventsLog = "events-log"
+	SourceFile      = "file"
+
+	DefaultPollInterval = 10 * time.Second
+	defaultSSHPort      = "29418"
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+	// maxEventSize bounds a single stream-events line, large comments
+	// included
+	maxEventSize = 4 << 20
+	// eventsLogTimeFormat is the format of the t1 parameter of the
+	// events-log plugin
+	eventsLogTimeFormat = "2006-01-02 15:04:05"
+)
+
+// Config selects where Gerrit events are read from.
+type Config struct {
+	// Source is "ssh", "events-log" or "file", events are not read when empty.
+	Source string `yaml:"Source"`
+	// SSHAddress is the user@host[:port] of the Gerrit SSH daemon, the user
+	// needs the Stream Events capability.
+	SSHAddress string `yaml:"SSHAddress"`
+	// SSHKeyFile is the private key used by ssh, the ssh defaults apply when
+	// empty.
+	SSHKeyFile string `yaml:"SSHKeyFile"`
+	// File holds stream-events JSON lines to replay, e.g. captured with
+	// "ssh gerrit stream-events > events.json".
+	File string `yaml:"File"`
+	// PollInterval is how often the events-log plugin is queried.
+	PollInterval time.Duration `yaml:"PollInterval"`
+	// BufferSize is the number of recent events kept for list_recent_events.
+	BufferSize int `yaml:"BufferSize"`
+	// Webhook receives events from the webhooks plugin, in addition to or
+	// instead of Source.
+	Webhook WebhookConfig `yaml:"Webhook"`
+}
+
+// Reader is a source of Gerrit events in the stream-events JSON format.
+type Reader interface {
+	// Stream sends each event line to lines until ctx is done or the
+	// source fails. It returns nil once a finite source is exhausted.
+	Stream(ctx context.Context, lines chan<- []byte) error
+}
+
+// NewReader returns the Reader of the configured source.
+func NewReader(cfg Config, gerritClient *gerrit.Client) (Reader, error) {
+	switch cfg.Source {
+	case SourceSSH:
+		if cfg.SSHAddress == "" {
+			return nil, fmt.Errorf("an SSH address is required to stream events over SSH")
+		}
+		return NewSSHReader(cfg.SSHAddress, cfg.SSHKeyFile)
+	case SourceEventsLog:
+		return NewEventsLogReader(gerritClient, cfg.PollInterval), nil
+	case SourceFile:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("a file is required to replay events")
+		}
+		return &FileReader{Path: cfg.File}, nil
+	}
+	return nil, fmt.Errorf("unknown event source %q", cfg.Source)
+}
+
+// scanLines sends the lines of r to lines.
+func scanLines(ctx context.Context, r io.Reader, lines chan<- []byte) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxEventSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		select {
+		case lines <- bytes.Clone(line):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// FileReader replays the events of a file of stream-events JSON lines.
+type FileReader struct {
+	Path string
+}
+
+func (r *FileReader) Stream(ctx context.Context, lines chan<- []byte) error {
+	file, err := os.Open(r.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return scanLines(ctx, file, lines)
+}
+
+// SSHReader runs "gerrit stream-events" with the ssh client.
+type SSHReader struct {
+	args []string
+}
+
+func NewSSHReader(address, keyFile string) (*SSHReader, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, defaultSSHPort
+	}
+	if host == "" {
+		return nil, fmt.Errorf("invalid SSH address %q", address)
+	}
+	args := []string{"-p", port, "-o", "BatchMode=yes", "-o", "ServerAliveInterval=30"}
+	if keyFile != "" {
+		args = append(args, "-i", keyFile)
+	}
+	args = append(args, host, "gerrit", "stream-events")
+	return &SSHReader{args: args}, nil
+}
+
+func (r *SSHReader) Stream(ctx context.Context, lines chan<- []byte) error {
+	cmd := exec.CommandContext(ctx, "ssh", r.args...)
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanErr := scanLines(ctx, stdout, lines)
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		err = errors.Join(err, errors.New(message))
+	}
+	// the stream never ends on its own, reconnect even after a clean exit
+	return fmt.Errorf("ssh stream-events ended: %w", errors.Join(scanErr, err))
+}
+
+// EventsLogReader polls the REST API of the events-log plugin, which keeps
+// the events of the last days so that none are lost between polls or while
+// the server is down.
+type EventsLogReader struct {
+	gerritClient *gerrit.Client
+	interval     time.Duration
+	since        time.Time
+	// seen holds the events of the second of since, which the next poll
+	// returns again
+	seen map[string]bool
+}
+
+func NewEventsLogReader(gerritClient *gerrit.Client, interval time.Duration) *EventsLogReader {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &EventsLogReader{
+		gerritClient: gerritClient,
+		interval:     interval,
+		since:        time.Now().UTC().Truncate(time.Second),
+		seen:         make(map[string]bool),
+	}
+}
+
+func (r *EventsLogReader) Stream(ctx context.Context, lines chan<- []byte) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.poll(ctx, lines); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *EventsLogReader) poll(ctx context.Context, lines chan<- []byte) error {
+	req, err := r.gerritClient.NewRequest(ctx, "GET",
+		"plugins/events-log/events/?t1="+url.QueryEscape(r.since.Format(eventsLogTimeFormat)), nil)
+	if err != nil {
+		return err
+	}
+	body := bytes.Buffer{}
+	if _, err := r.gerritClient.Do(req, &body); err != nil {
+		return fmt.Errorf("failed to poll the events-log plugin: %w", err)
+	}
+	received := make(chan []byte)
+	done := make(chan error, 1)
+	go func() {
+		done <- scanLines(ctx, &body, received)
+		close(received)
+	}()
+	for line := range received {
+		if !bytes.HasPrefix(line, []byte("{")) {
+			continue
+		}
+		event, err := Parse(line)
+		if err != nil {
+			logger.Errorf("Skipping event from the events-log plugin: %v", err)
+			continue
+		}
+		if event.Time.Before(r.since) || r.seen[string(line)] {
+			continue
+		}
+		if event.Time.After(r.since) {
+			r.since = event.Time
+			r.seen = make(map[string]bool)
+		}
+		r.seen[string(line)] = true
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return <-done
+}
+
+// Run reads events from reader and publishes them to bus until ctx is done
+// or a finite reader is exhausted. Failed sources are reconnected with an
+// exponential backoff, and resync is called after each connection so that
+// updates missed while disconnected can be caught up.
+func Run(ctx context.Context, reader Reader, bus *Bus, resync func(context.Context)) {
+	delay := minReconnectDelay
+	for {
+		lines := make(chan []byte)
+		done := make(chan error, 1)
+		go func() {
+			done <- reader.Stream(ctx, lines)
+		}()
+		if resync != nil {
+			resync(ctx)
+		}
+		received := 0
+		var err error
+	read:
+		for {
+			select {
+			case line := <-lines:
+				received++
+				event, parseErr := Parse(line)
+				if parseErr != nil {
+					logger.Errorf("Skipping Gerrit event: %v", parseErr)
+					continue
+				}
+				metrics.GerritEvents.WithLabelValues(event.Type).Inc()
+				bus.Publish(event)
+			case err = <-done:
+				break read
+			}
+		}
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if received > 0 {
+			delay = minReconnectDelay
+		}
+		logger.Errorf("Gerrit event source failed, reconnecting in %v: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
@@ -0,0 +1,55 @@
+package events
+
+import "sync"
+
+const DefaultBufferSize = 1000
+
+// Ring keeps the most recent events.
+type Ring struct {
+	mu     sync.Mutex
+	events []Event
+	// next is the index of the slot written next
+	next int
+	full bool
+}
+
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Ring{events: make([]Event, size)}
+}
+
+// Add stores an event, evicting the oldest one when the ring is full.
+func (r *Ring) Add(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[r.next] = event
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Recent returns up to limit events accepted by keep, newest first. A
+// limit of zero or less returns them all.
+func (r *Ring) Recent(limit int, keep func(Event) bool) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.events)
+	}
+	recent := make([]Event, 0)
+	for i := 1; i <= count; i++ {
+		event := r.events[(r.next-i+len(r.events))%len(r.events)]
+		if keep != nil && !keep(event) {
+			continue
+		}
+		recent = append(recent, event)
+		if limit > 0 && len(recent) == limit {
+			break
+		}
+	}
+	return recent
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/metrics"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultWebhookPath = "/webhooks/gerrit"
+
+	// SignatureHeader carries the hex HMAC-SHA256 of the body keyed with
+	// the secret, prefixed with "sha256=".
+	SignatureHeader = "X-Hub-Signature-256"
+	// TokenHeader carries the shared secret itself, which may also be given
+	// in the token query parameter of the URL configured in Gerrit.
+	TokenHeader = "X-Webhook-Token"
+)
+
+// WebhookConfig of the endpoint receiving the deliveries of the Gerrit
+// webhooks plugin. It is disabled without a secret.
+type WebhookConfig struct {
+	Path   string `yaml:"Path"`
+	Secret string `yaml:"Secret"`
+}
+
+func (c WebhookConfig) Enabled() bool {
+	return c.Secret != ""
+}
+
+// NewWebhookHandler returns the handler of webhook deliveries, which
+// publishes the events of authentic deliveries to bus.
+func NewWebhookHandler(secret string, bus *Bus) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventSize))
+		if err != nil {
+			http.Error(w, "unable to read the delivery", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !verifyDelivery(r, body, secret) {
+			logger.Errorf("Rejected webhook delivery from %s: invalid signature or token", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		event, err := Parse(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		metrics.GerritEvents.WithLabelValues(event.Type).Inc()
+		bus.Publish(event)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// verifyDelivery accepts a delivery signed with the secret, or carrying the
+// secret itself.
+func verifyDelivery(r *http.Request, body []byte, secret string) bool {
+	if signature := r.Header.Get(SignatureHeader); signature != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+	token := r.Header.Get(TokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
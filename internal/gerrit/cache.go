@@ -0,0 +1,177 @@
+package gerrit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"gerrit-mcp/internal/cache"
+	"gerrit-mcp/internal/logger"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultCacheSizeMB   = 64
+	DefaultDiskSizeMB    = 1024
+	DefaultProjectsTTL   = 10 * time.Minute
+	DefaultChangesTTL    = time.Minute
+	CacheKindImmutable   = "immutable"
+	CacheKindTTL         = "ttl"
+	cacheKindUncacheable = ""
+)
+
+// revision scoped endpoints addressed by a commit SHA never change
+var immutableRevisionPath = regexp.MustCompile(`^/changes/[^/]+/revisions/[0-9a-f]{40}/(files/|files/.+/(diff|content)$|patch$|commit$)`)
+
+type CacheConfig struct {
+	Enabled     bool          `yaml:"Enabled"`
+	MaxSizeMB   int           `yaml:"MaxSizeMB"`
+	Dir         string        `yaml:"Dir"`
+	ProjectsTTL time.Duration `yaml:"ProjectsTTL"`
+	ChangesTTL  time.Duration `yaml:"ChangesTTL"`
+	// DiskMaxSizeMB bounds the files under Dir, the least recently used are
+	// pruned beyond it.
+	DiskMaxSizeMB int `yaml:"DiskMaxSizeMB"`
+}
+
+type CacheStats struct {
+	Hits   map[string]uint64
+	Misses map[string]uint64
+	Memory cache.MemoryStats
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("immutable %d hits/%d misses, ttl %d hits/%d misses, %d entries (%d bytes), %d evictions",
+		s.Hits[CacheKindImmutable], s.Misses[CacheKindImmutable], s.Hits[CacheKindTTL], s.Misses[CacheKindTTL],
+		s.Memory.Entries, s.Memory.SizeBytes, s.Memory.Evictions)
+}
+
+type noCacheKey struct{}
+
+// WithoutCache makes the requests sent with ctx bypass the cache, for callers
+// that must not be answered with data up to a TTL old.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+type cacheCounters struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// CachingTransport caches successful Gerrit GET responses: revision scoped
+// data keyed by commit SHA forever, project lists and change metadata for a
+// TTL.
+type CachingTransport struct {
+	Base        http.RoundTripper
+	memory      *cache.Memory
+	store       cache.Store
+	projectsTTL time.Duration
+	changesTTL  time.Duration
+	counters    map[string]*cacheCounters
+}
+
+func NewCachingTransport(base http.RoundTripper, cfg CacheConfig) (*CachingTransport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if cfg.MaxSizeMB <= 0 {
+		cfg.MaxSizeMB = DefaultCacheSizeMB
+	}
+	if cfg.DiskMaxSizeMB <= 0 {
+		cfg.DiskMaxSizeMB = DefaultDiskSizeMB
+	}
+	if cfg.ProjectsTTL <= 0 {
+		cfg.ProjectsTTL = DefaultProjectsTTL
+	}
+	if cfg.ChangesTTL <= 0 {
+		cfg.ChangesTTL = DefaultChangesTTL
+	}
+	memory := cache.NewMemory(int64(cfg.MaxSizeMB) * 1024 * 1024)
+	store := &cache.Tiered{Memory: memory}
+	if cfg.Dir != "" {
+		disk, err := cache.NewDisk(cfg.Dir, int64(cfg.DiskMaxSizeMB)*1024*1024)
+		if err != nil {
+			return nil, err
+		}
+		store.Disk = disk
+	}
+	return &CachingTransport{
+		Base:        base,
+		memory:      memory,
+		store:       store,
+		projectsTTL: cfg.ProjectsTTL,
+		changesTTL:  cfg.ChangesTTL,
+		counters: map[string]*cacheCounters{
+			CacheKindImmutable: {},
+			CacheKindTTL:       {},
+		},
+	}, nil
+}
+
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	kind, ttl := t.classify(req)
+	if kind == cacheKindUncacheable {
+		return t.Base.RoundTrip(req)
+	}
+	key := req.URL.String()
+	if data, ok := t.store.Get(key); ok {
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+		if err == nil {
+			t.counters[kind].hits.Add(1)
+			return resp, nil
+		}
+		logger.Errorf("dropping unreadable cache entry for %s: %v", key, err)
+	}
+	t.counters[kind].misses.Add(1)
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+	t.store.Set(key, data, ttl)
+	// DumpResponse replaced the consumed body with an in-memory copy
+	return resp, nil
+}
+
+// classify tells whether the request can be cached and for how long.
+func (t *CachingTransport) classify(req *http.Request) (string, time.Duration) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || req.Context().Value(noCacheKey{}) != nil {
+		return cacheKindUncacheable, 0
+	}
+	// authenticated requests are prefixed with /a
+	path := req.URL.EscapedPath()
+	if strings.HasPrefix(path, "/a/") {
+		path = path[2:]
+	}
+	switch {
+	case immutableRevisionPath.MatchString(path):
+		return CacheKindImmutable, 0
+	case path == "/projects/":
+		return CacheKindTTL, t.projectsTTL
+	case strings.HasPrefix(path, "/changes/") && !strings.Contains(path, "/revisions/"):
+		return CacheKindTTL, t.changesTTL
+	}
+	return cacheKindUncacheable, 0
+}
+
+func (t *CachingTransport) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:   make(map[string]uint64, len(t.counters)),
+		Misses: make(map[string]uint64, len(t.counters)),
+		Memory: t.memory.Stats(),
+	}
+	for kind, c := range t.counters {
+		stats.Hits[kind] = c.hits.Load()
+		stats.Misses[kind] = c.misses.Load()
+	}
+	return stats
+}
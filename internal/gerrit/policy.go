@@ -0,0 +1,245 @@
+package gerrit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultMaxRetries      = 3
+	DefaultRetryBaseDelay  = 200 * time.Millisecond
+	DefaultRetryMaxDelay   = 10 * time.Second
+	DefaultBreakerFailures = 10
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("gerrit circuit breaker is open")
+
+// PolicyConfig is the traffic policy toward a Gerrit instance.
+type PolicyConfig struct {
+	// RequestsPerSecond is the token bucket refill rate, 0 disables rate limiting.
+	RequestsPerSecond float64 `yaml:"RequestsPerSecond"`
+	Burst             int     `yaml:"Burst"`
+	// MaxRetries of idempotent requests failing with 429, 5xx or a network error, -1 disables retries.
+	MaxRetries     int           `yaml:"MaxRetries"`
+	RetryBaseDelay time.Duration `yaml:"RetryBaseDelay"`
+	RetryMaxDelay  time.Duration `yaml:"RetryMaxDelay"`
+	// BreakerFailures is the number of consecutive failures opening the circuit, -1 disables it.
+	BreakerFailures int           `yaml:"BreakerFailures"`
+	BreakerCooldown time.Duration `yaml:"BreakerCooldown"`
+}
+
+func (c PolicyConfig) withDefaults() PolicyConfig {
+	if c.Burst <= 0 {
+		c.Burst = max(1, int(c.RequestsPerSecond))
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+	if c.RetryBaseDelay <= 0 {
+		c.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+	if c.RetryMaxDelay <= 0 {
+		c.RetryMaxDelay = DefaultRetryMaxDelay
+	}
+	if c.BreakerFailures == 0 {
+		c.BreakerFailures = DefaultBreakerFailures
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = DefaultBreakerCooldown
+	}
+	return c
+}
+
+// PolicyTransport applies a PolicyConfig to the requests sent to a Gerrit
+// instance: token bucket rate limiting, retries with jittered exponential
+// backoff honouring Retry-After, and a circuit breaker.
+type PolicyTransport struct {
+	Base    http.RoundTripper
+	config  PolicyConfig
+	limiter *rate.Limiter
+	breaker *circuitBreaker
+}
+
+func NewPolicyTransport(base http.RoundTripper, cfg PolicyConfig) *PolicyTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cfg = cfg.withDefaults()
+	t := &PolicyTransport{Base: base, config: cfg}
+	if cfg.RequestsPerSecond > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)
+	}
+	if cfg.BreakerFailures > 0 {
+		t.breaker = &circuitBreaker{threshold: cfg.BreakerFailures, cooldown: cfg.BreakerCooldown}
+	}
+	return t
+}
+
+func (t *PolicyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		// a nil breaker allows every request
+		allowed, probe := t.breaker.allow()
+		if !allowed {
+			return nil, fmt.Errorf("%w: %s %s", ErrCircuitOpen, req.Method, req.URL.Host)
+		}
+		if t.limiter != nil {
+			if err := t.limiter.Wait(ctx); err != nil {
+				t.breaker.release(probe)
+				return nil, err
+			}
+		}
+		resp, err := t.Base.RoundTrip(req)
+		failed := err != nil || retryableStatus(resp.StatusCode)
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			// the caller gave up, which says nothing of the health of
+			// Gerrit, unlike a deadline exceeded while waiting for it
+			t.breaker.release(probe)
+		case resp != nil && resp.StatusCode == http.StatusTooManyRequests:
+			// a 429 comes from a healthy server throttling us, the rate
+			// limit and Retry-After handle it rather than the breaker
+			t.breaker.release(probe)
+		default:
+			t.breaker.record(!failed)
+		}
+		if ctx.Err() != nil || !failed || !isIdempotent(req) || attempt >= t.config.MaxRetries {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > t.config.RetryMaxDelay {
+					// the server asks to wait longer than we are willing to
+					return resp, nil
+				}
+				delay = max(delay, retryAfter)
+			}
+			// drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		logger.FromContext(ctx).Debugf("retrying %s %s in %v (attempt %d): status %s, error %v",
+			req.Method, req.URL.Path, delay, attempt+1, statusOf(resp), err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a full-jitter exponential delay for the given attempt.
+func (t *PolicyTransport) backoff(attempt int) time.Duration {
+	ceiling := t.config.RetryBaseDelay << min(attempt, 30)
+	if ceiling <= 0 || ceiling > t.config.RetryMaxDelay {
+		ceiling = t.config.RetryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+func isIdempotent(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && (req.Body == nil || req.Body == http.NoBody)
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func statusOf(resp *http.Response) string {
+	if resp == nil {
+		return "none"
+	}
+	return resp.Status
+}
+
+// parseRetryAfter supports both delay-seconds and HTTP-date values.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(date)), true
+	}
+	return 0, false
+}
+
+// circuitBreaker opens after threshold consecutive failures and lets a
+// single probe request through once cooldown has elapsed.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a request may be sent, and whether it is the probe
+// of an open circuit, which must be followed by record or release.
+func (b *circuitBreaker) allow() (allowed, probe bool) {
+	if b == nil {
+		return true, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// release ends a request that tells nothing of the health of Gerrit, letting
+// another request probe the circuit when it was the probe.
+func (b *circuitBreaker) release(probe bool) {
+	if b == nil || !probe {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasProbing := b.probing
+	b.probing = false
+	if success {
+		if b.failures >= b.threshold {
+			logger.Infof("gerrit circuit breaker closed")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures == b.threshold || wasProbing {
+		b.openUntil = time.Now().Add(b.cooldown)
+		logger.Errorf("gerrit circuit breaker opened for %v after %d consecutive failures", b.cooldown, b.failures)
+	}
+}
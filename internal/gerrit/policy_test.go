@@ -0,0 +1,109 @@
+package gerrit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// statusTransport answers each request with the next status of statuses.
+func statusTransport(statuses ...int) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+}
+
+func get(t *testing.T, transport http.RoundTripper, ctx context.Context) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://gerrit.example.com/changes/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+const testCooldown = 10 * time.Millisecond
+
+func TestBreakerProbeThrottled(t *testing.T) {
+	transport := NewPolicyTransport(statusTransport(http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK),
+		PolicyConfig{MaxRetries: -1, BreakerFailures: 1, BreakerCooldown: testCooldown})
+	ctx := context.Background()
+	get(t, transport, ctx)
+	if _, err := get(t, transport, ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("request after a failure error = %v, want %v", err, ErrCircuitOpen)
+	}
+	time.Sleep(testCooldown)
+	if resp, err := get(t, transport, ctx); err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("probe = %v, %v, want a 429", resp, err)
+	}
+	// the throttled probe neither closed nor reopened the circuit
+	if resp, err := get(t, transport, ctx); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request after a throttled probe = %v, %v, want another probe", resp, err)
+	}
+	if resp, err := get(t, transport, ctx); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request after a successful probe = %v, %v", resp, err)
+	}
+}
+
+func TestBreakerProbeRateLimited(t *testing.T) {
+	// a single token, spent by the failing request
+	transport := NewPolicyTransport(statusTransport(http.StatusBadGateway, http.StatusOK),
+		PolicyConfig{RequestsPerSecond: 0.001, Burst: 1, MaxRetries: -1, BreakerFailures: 1, BreakerCooldown: testCooldown})
+	get(t, transport, context.Background())
+	time.Sleep(testCooldown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := get(t, transport, ctx); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe waiting for a token beyond its deadline error = %v", err)
+	}
+	if allowed, probe := transport.breaker.allow(); !allowed || !probe {
+		t.Errorf("breaker after a rate limited probe allow() = %t, %t, want another probe", allowed, probe)
+	}
+}
+
+func TestBreakerCountsDeadlines(t *testing.T) {
+	// a Gerrit hanging until the requests time out, or are canceled
+	hanging := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	transport := NewPolicyTransport(hanging, PolicyConfig{MaxRetries: -1, BreakerFailures: 2, BreakerCooldown: time.Minute})
+
+	for range 3 {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := get(t, transport, ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("canceled request error = %v", err)
+		}
+	}
+	for range 2 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		_, err := get(t, transport, ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("timed out request error = %v", err)
+		}
+	}
+	if _, err := get(t, transport, context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("request after 2 timeouts error = %v, want %v", err, ErrCircuitOpen)
+	}
+}
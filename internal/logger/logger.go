@@ -1,50 +1,141 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Config of the process logger.
+type Config struct {
+	// Format is "console" (the default) or "json".
+	Format string `yaml:"Format"`
+	// Level is debug, info, warn or error. It defaults to debug when the
+	// DEBUG environment variable is "true" and info otherwise.
+	Level string `yaml:"Level"`
+	// Output is stdout (the default), stderr or a file path.
+	Output string `yaml:"Output"`
+}
+
 var ZapLog *zap.Logger
 var SugarLog *zap.SugaredLogger
 
+// Level is the level of every logger, it can be changed at runtime.
+var Level = zap.NewAtomicLevel()
+
+// wrapped is used by the package level helpers so that entries report
+// their callers instead of this file.
+var wrapped *zap.SugaredLogger
+
 func init() {
+	if err := Setup(Config{}); err != nil {
+		panic(err)
+	}
+}
+
+// Setup replaces the process logger. Secrets are redacted from every
+// entry, see redactingCore. Loggers already stored in contexts
+// keep the previous configuration.
+func Setup(cfg Config) error {
 	level := zapcore.InfoLevel
 	if os.Getenv("DEBUG") == "true" {
 		level = zapcore.DebugLevel
 	}
-	var err error
+	if cfg.Level != "" {
+		var err error
+		if level, err = zapcore.ParseLevel(cfg.Level); err != nil {
+			return err
+		}
+	}
+	if cfg.Output == "" {
+		cfg.Output = "stdout"
+	}
 	config := zap.Config{
-		Encoding:    "console",
-		Level:       zap.NewAtomicLevelAt(level),
-		OutputPaths: []string{"stdout"},
-		// TODO: change to ProductionDevelopmentConfig ?
-		EncoderConfig: zap.NewDevelopmentEncoderConfig(),
-	}
-	ZapLog, err = config.Build()
-	defer func() {
-		err = ZapLog.Sync()
-	}()
+		Level:            Level,
+		OutputPaths:      []string{cfg.Output},
+		ErrorOutputPaths: []string{"stderr"},
+	}
+	switch cfg.Format {
+	case "", FormatConsole:
+		config.Encoding = FormatConsole
+		config.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	case FormatJSON:
+		config.Encoding = FormatJSON
+		config.EncoderConfig = zap.NewProductionEncoderConfig()
+		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		// "caller" is the authenticated MCP caller in request-scoped loggers
+		config.EncoderConfig.CallerKey = "source"
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	zapLog, err := config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return redactingCore{core}
+	}))
 	if err != nil {
-		panic(err)
+		return err
+	}
+	Level.SetLevel(level)
+	if ZapLog != nil {
+		ZapLog.Sync()
 	}
+	ZapLog = zapLog
 	SugarLog = ZapLog.Sugar()
+	wrapped = ZapLog.WithOptions(zap.AddCallerSkip(1)).Sugar()
+	return nil
+}
+
+// LevelHandler serves the current level as JSON on GET and changes it on
+// PUT, e.g. {"level":"debug"}.
+func LevelHandler() http.Handler {
+	return Level
 }
 
 func Infof(message string, args ...interface{}) {
-	SugarLog.Infof(message, args...)
+	wrapped.Infof(message, args...)
 }
 
 func Debugf(message string, args ...interface{}) {
-	SugarLog.Debugf(message, args...)
+	wrapped.Debugf(message, args...)
+}
+
+func Warnf(message string, args ...interface{}) {
+	wrapped.Warnf(message, args...)
 }
 
 func Errorf(message string, args ...interface{}) {
-	SugarLog.Errorf(message, args...)
+	wrapped.Errorf(message, args...)
 }
 
 func Fatalf(message string, args ...interface{}) {
-	SugarLog.Fatalf(message, args...)
+	wrapped.Fatalf(message, args...)
+}
+
+// Sync flushes buffered log entries.
+func Sync() error {
+	return ZapLog.Sync()
+}
+
+type contextKey struct{}
+
+// WithFields returns a context carrying a logger with the given key/value
+// pairs added to the one already stored in ctx.
+func WithFields(ctx context.Context, args ...interface{}) context.Context {
+	return context.WithValue(ctx, contextKey{}, FromContext(ctx).With(args...))
+}
+
+// FromContext returns the request-scoped logger, or the global one.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if l, ok := ctx.Value(contextKey{}).(*zap.SugaredLogger); ok {
+		return l
+	}
+	return SugarLog
 }
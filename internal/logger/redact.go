@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"fmt"
+	"gerrit-mcp/internal/redact"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redactingCore removes secrets from the message and the string-like fields
+// of every entry before it is encoded.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = redact.String(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = redact.String(field.String)
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field = zap.String(field.Key, redact.String(err.Error()))
+			}
+		case zapcore.StringerType, zapcore.ReflectType:
+			field = zap.String(field.Key, redact.String(fmt.Sprint(field.Interface)))
+		case zapcore.ByteStringType:
+			field = zap.String(field.Key, redact.String(string(field.Interface.([]byte))))
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
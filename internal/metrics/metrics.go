@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gerrit_mcp"
+
+// Registry holds every metric exported by the server.
+var Registry = prometheus.NewRegistry()
+
+var (
+	ToolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Tool calls by tool and result status.",
+	}, []string{"tool", "status"})
+	ToolErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_errors_total",
+		Help:      "Failed tool calls by tool and error code.",
+	}, []string{"tool", "code"})
+	ToolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_call_duration_seconds",
+		Help:      "Tool call latency.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"tool"})
+	GerritRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gerrit_requests_total",
+		Help:      "Gerrit REST requests by method, endpoint and status code.",
+	}, []string{"method", "endpoint", "code"})
+	GerritRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gerrit_request_duration_seconds",
+		Help:      "Gerrit REST request latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "endpoint"})
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "MCP HTTP requests being served.",
+	})
+	ActiveSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Registered MCP sessions.",
+	})
+	GerritEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gerrit_events_total",
+		Help:      "Gerrit events received by type.",
+	}, []string{"type"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ToolCalls, ToolErrors, ToolDuration,
+		GerritRequests, GerritRequestDuration,
+		HTTPInFlight, ActiveSessions,
+		GerritEvents,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// InstrumentHandler tracks the requests in flight of an MCP HTTP handler.
+func InstrumentHandler(next http.Handler) http.Handler {
+	return promhttp.InstrumentHandlerInFlight(HTTPInFlight, next)
+}
+
+// CacheStatsFunc reports cache hits and misses by cache kind.
+type CacheStatsFunc func() (hits map[string]uint64, misses map[string]uint64)
+
+type cacheCollector struct {
+	stats    CacheStatsFunc
+	hits     *prometheus.Desc
+	misses   *prometheus.Desc
+	hitRatio *prometheus.Desc
+}
+
+// RegisterCacheStats exports the hit/miss counters and hit ratio of a cache.
+func RegisterCacheStats(stats CacheStatsFunc) error {
+	return Registry.Register(&cacheCollector{
+		stats:    stats,
+		hits:     prometheus.NewDesc(namespace+"_cache_hits_total", "Gerrit response cache hits.", []string{"kind"}, nil),
+		misses:   prometheus.NewDesc(namespace+"_cache_misses_total", "Gerrit response cache misses.", []string{"kind"}, nil),
+		hitRatio: prometheus.NewDesc(namespace+"_cache_hit_ratio", "Gerrit response cache hit ratio since start.", []string{"kind"}, nil),
+	})
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.hitRatio
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	hits, misses := c.stats()
+	for kind, hit := range hits {
+		miss := misses[kind]
+		ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(hit), kind)
+		ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(miss), kind)
+		ratio := 0.0
+		if hit+miss > 0 {
+			ratio = float64(hit) / float64(hit+miss)
+		}
+		ch <- prometheus.MustNewConstMetric(c.hitRatio, prometheus.GaugeValue, ratio, kind)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport counts Gerrit REST requests and their latency per endpoint.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := Endpoint(req.URL.EscapedPath())
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	GerritRequestDuration.WithLabelValues(req.Method, endpoint).Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	GerritRequests.WithLabelValues(req.Method, endpoint, code).Inc()
+	return resp, err
+}
+
+// identifierAfter names the path segments following a collection segment
+// that identify an entity and must not become label values.
+var identifierAfter = map[string]string{
+	"changes":   "{change}",
+	"revisions": "{revision}",
+	"projects":  "{project}",
+	"accounts":  "{account}",
+	"branches":  "{branch}",
+	"comments":  "{comment}",
+	"drafts":    "{draft}",
+	"reviewers": "{reviewer}",
+}
+
+// Endpoint turns a Gerrit REST path into a low cardinality endpoint name,
+// e.g. /changes/{change}/revisions/{revision}/files/{file}/diff.
+func Endpoint(path string) string {
+	// authenticated requests are prefixed with /a
+	path = strings.TrimPrefix(path, "/a/")
+	path = strings.TrimPrefix(path, "/")
+	segments := strings.Split(path, "/")
+	normalized := make([]string, 0, len(segments))
+	for i := 0; i < len(segments); i++ {
+		segment := segments[i]
+		normalized = append(normalized, segment)
+		if segment == "files" && i+1 < len(segments) && segments[i+1] != "" {
+			// file paths are URL encoded in a single segment, but may also span several
+			normalized = append(normalized, "{file}")
+			last := len(segments) - 1
+			if suffix := segments[last]; last > i+1 && (suffix == "diff" || suffix == "content" || suffix == "blame") {
+				normalized = append(normalized, suffix)
+			}
+			break
+		}
+		if placeholder, ok := identifierAfter[segment]; ok && i+1 < len(segments) && segments[i+1] != "" {
+			normalized = append(normalized, placeholder)
+			i++
+		}
+	}
+	return "/" + strings.Join(normalized, "/")
+}
@@ -0,0 +1,141 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIKey is a named key from the keyfile. Only the SHA-256 hash of the key is
+// stored, as "sha256:<hex>".
+type APIKey struct {
+	Name          string    `yaml:"name"`
+	Hash          string    `yaml:"hash"`
+	Scopes        []string  `yaml:"scopes"`
+	Projects      []string  `yaml:"projects"`
+	Tools         []string  `yaml:"tools"`
+	DisabledTools []string  `yaml:"disabled_tools"`
+	Expires       time.Time `yaml:"expires"`
+
+	digest []byte
+}
+
+type apiKeyFile struct {
+	Keys []APIKey `yaml:"keys"`
+}
+
+// APIKeyValidator validates bearer tokens against a keyfile of named API keys.
+// The keyfile can be reloaded at runtime with Reload.
+type APIKeyValidator struct {
+	HeaderName string
+	path       string
+
+	mu   sync.RWMutex
+	keys []APIKey
+}
+
+func NewAPIKeyValidator(headerName string, path string) (*APIKeyValidator, error) {
+	v := &APIKeyValidator{HeaderName: headerName, path: path}
+	if err := v.Reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Reload re-reads the keyfile. On error the previously loaded keys are kept.
+func (v *APIKeyValidator) Reload() error {
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseAPIKeys(data)
+	if err != nil {
+		return fmt.Errorf("invalid keyfile %s: %w", v.path, err)
+	}
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func parseAPIKeys(data []byte) ([]APIKey, error) {
+	var file apiKeyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(file.Keys))
+	for i := range file.Keys {
+		key := &file.Keys[i]
+		if key.Name == "" {
+			return nil, fmt.Errorf("key #%d has no name", i)
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("duplicate key name %q", key.Name)
+		}
+		names[key.Name] = true
+		hexDigest, ok := strings.CutPrefix(key.Hash, "sha256:")
+		if !ok {
+			return nil, fmt.Errorf("key %q: hash must be in sha256:<hex> form", key.Name)
+		}
+		digest, err := hex.DecodeString(hexDigest)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("key %q: invalid sha256 hash", key.Name)
+		}
+		key.digest = digest
+		for _, patterns := range [][]string{key.Tools, key.DisabledTools} {
+			if err := ValidatePatterns(patterns); err != nil {
+				return nil, fmt.Errorf("key %q: %w", key.Name, err)
+			}
+		}
+	}
+	return file.Keys, nil
+}
+
+func (v *APIKeyValidator) IsDisabled() bool {
+	return false
+}
+
+func (v *APIKeyValidator) Extract(ctx context.Context, header http.Header) string {
+	return header.Get(v.HeaderName)
+}
+
+func (v *APIKeyValidator) Validate(token string) (any, error) {
+	raw, ok := strings.CutPrefix(token, "Bearer ")
+	if !ok {
+		return nil, fmt.Errorf("bearer token expected")
+	}
+	digest := sha256.Sum256([]byte(strings.TrimSpace(raw)))
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	var found *APIKey
+	// compare against every key so the timing does not depend on the match position
+	for i := range v.keys {
+		if subtle.ConstantTimeCompare(digest[:], v.keys[i].digest) == 1 {
+			found = &v.keys[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown API key")
+	}
+	if !found.Expires.IsZero() && time.Now().After(found.Expires) {
+		return nil, fmt.Errorf("API key %s expired", found.Name)
+	}
+	return &Claims{
+		Subject:       found.Name,
+		Scopes:        found.Scopes,
+		Projects:      found.Projects,
+		Tools:         found.Tools,
+		DisabledTools: found.DisabledTools,
+		ExpiresAt:     found.Expires,
+	}, nil
+}
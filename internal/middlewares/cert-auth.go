@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"crypto/x509"
+	"fmt"
+	"gerrit-mcp/internal/certs"
+	"path"
+)
+
+// CertIdentity grants scopes to the clients whose verified certificate
+// identity (see certs.Identity) matches Subject, an exact name or a
+// path.Match pattern.
+type CertIdentity struct {
+	Subject       string   `yaml:"subject"`
+	Scopes        []string `yaml:"scopes"`
+	Projects      []string `yaml:"projects"`
+	Tools         []string `yaml:"tools"`
+	DisabledTools []string `yaml:"disabled_tools"`
+}
+
+// CertificateValidator maps verified client certificates to claims.
+type CertificateValidator struct {
+	identities []CertIdentity
+}
+
+func NewCertificateValidator(identities []CertIdentity) *CertificateValidator {
+	return &CertificateValidator{identities: identities}
+}
+
+// Validate returns the claims of the first identity matching the leaf
+// certificate. The chain must already be verified by the TLS handshake.
+func (v *CertificateValidator) Validate(cert *x509.Certificate) (*Claims, error) {
+	subject := certs.Identity(cert)
+	for _, identity := range v.identities {
+		matched := identity.Subject == subject
+		if !matched {
+			matched, _ = path.Match(identity.Subject, subject)
+		}
+		if matched {
+			return &Claims{
+				Subject:       subject,
+				Issuer:        cert.Issuer.String(),
+				Scopes:        identity.Scopes,
+				Projects:      identity.Projects,
+				Tools:         identity.Tools,
+				DisabledTools: identity.DisabledTools,
+				ExpiresAt:     cert.NotAfter,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown client certificate %q", subject)
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// Claims is the identity extracted from a validated token. It is what
+// validators return as token scopes.
+type Claims struct {
+	Subject  string
+	Issuer   string
+	Audience []string
+	Scopes   []string
+	// Projects limits the token to the listed projects (exact names or
+	// path.Match patterns). Empty means every project.
+	Projects []string
+	// Tools limits the token to the listed tools (exact names or path.Match
+	// patterns). Empty means every tool.
+	Tools []string
+	// DisabledTools denies the listed tools (exact names or path.Match
+	// patterns), even when they match Tools.
+	DisabledTools []string
+	ExpiresAt     time.Time
+	Raw           map[string]any
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Claims) AllowsTool(tool string) bool {
+	if MatchAny(c.DisabledTools, tool) {
+		return false
+	}
+	return len(c.Tools) == 0 || MatchAny(c.Tools, tool)
+}
+
+func (c *Claims) AllowsProject(project string) bool {
+	return len(c.Projects) == 0 || MatchAny(c.Projects, project)
+}
+
+// MatchAny reports whether name is one of patterns, exact names or
+// path.Match patterns.
+func MatchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePatterns returns an error naming the first malformed pattern.
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package middlewares
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultJWKSRefreshInterval = time.Hour
+	// minimal delay between two refreshes triggered by an unknown key id
+	jwksMinRefreshInterval = time.Minute
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// JWKS is a set of public keys loaded from a local file or a remote URL.
+// Remote sets are refreshed periodically and whenever an unknown key id is seen.
+type JWKS struct {
+	source          string
+	httpClient      *http.Client
+	refreshInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func NewJWKS(source string) (*JWKS, error) {
+	jwks := &JWKS{
+		source:          source,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: DefaultJWKSRefreshInterval,
+	}
+	if err := jwks.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+func (j *JWKS) isRemote() bool {
+	return strings.HasPrefix(j.source, "https://") || strings.HasPrefix(j.source, "http://")
+}
+
+// Key returns the public key for the given key id. An empty kid matches the
+// only key of a single-key set.
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, stale := j.lookup(kid)
+	if key != nil && !stale {
+		return key, nil
+	}
+	if j.isRemote() && j.canRefresh(key == nil) {
+		if err := j.refresh(ctx); err != nil {
+			if key != nil {
+				// keep serving the previously fetched key
+				return key, nil
+			}
+			return nil, err
+		}
+		key, _ = j.lookup(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (j *JWKS) lookup(kid string) (crypto.PublicKey, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	stale := j.isRemote() && time.Since(j.fetchedAt) > j.refreshInterval
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, stale
+		}
+	}
+	return j.keys[kid], stale
+}
+
+func (j *JWKS) canRefresh(missing bool) bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if missing {
+		return time.Since(j.fetchedAt) > jwksMinRefreshInterval
+	}
+	return time.Since(j.fetchedAt) > j.refreshInterval
+}
+
+func (j *JWKS) refresh(ctx context.Context) error {
+	data, err := j.read(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to read JWKS from %s: %w", j.source, err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("unable to parse JWKS from %s: %w", j.source, err)
+	}
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	if !j.isRemote() {
+		return os.ReadFile(j.source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set jsonWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// identity providers publish keys of several kinds, an
+			// unsupported one must not disable the others
+			logger.Warnf("Skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no supported signing keys found")
+	}
+	return keys, nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
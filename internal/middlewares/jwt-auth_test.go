@@ -0,0 +1,224 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "gerrit-mcp"
+)
+
+type testSigningKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+func encodeBigInt(n *big.Int, size int) string {
+	return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, size)))
+}
+
+// writeJWKS writes the public keys of keys to a JWKS file and returns its path.
+func writeJWKS(t *testing.T, keys ...testSigningKey) string {
+	t.Helper()
+	var set jsonWebKeySet
+	for _, k := range keys {
+		switch pub := k.key.Public().(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, jsonWebKey{
+				Kty: "RSA", Kid: k.kid, Use: "sig", Alg: k.method.Alg(),
+				N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			set.Keys = append(set.Keys, jsonWebKey{
+				Kty: "EC", Kid: k.kid, Use: "sig", Alg: k.method.Alg(), Crv: pub.Curve.Params().Name,
+				X: encodeBigInt(pub.X, size), Y: encodeBigInt(pub.Y, size),
+			})
+		default:
+			t.Fatalf("unsupported key type %T", pub)
+		}
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func signToken(t *testing.T, k testSigningKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + signed
+}
+
+func TestJWTTokenValidator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSigner := testSigningKey{kid: "rsa-1", method: jwt.SigningMethodRS256, key: rsaKey}
+	ecSigner := testSigningKey{kid: "ec-1", method: jwt.SigningMethodES256, key: ecKey}
+	unknownSigner := testSigningKey{kid: "unknown", method: jwt.SigningMethodES256, key: unknownKey}
+
+	validator, err := NewJWTTokenValidator("Authorization", writeJWKS(t, rsaSigner, ecSigner), testIssuer, testAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":   "alice",
+			"iss":   testIssuer,
+			"aud":   testAudience,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "gerrit:read",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		signer  testSigningKey
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{name: "valid RSA", signer: rsaSigner, claims: claims(nil)},
+		{name: "valid EC", signer: ecSigner, claims: claims(nil)},
+		{name: "expired", signer: rsaSigner, claims: claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), wantErr: true},
+		{name: "wrong issuer", signer: ecSigner, claims: claims(jwt.MapClaims{"iss": "https://evil.example.com"}), wantErr: true},
+		{name: "wrong audience", signer: rsaSigner, claims: claims(jwt.MapClaims{"aud": "another-service"}), wantErr: true},
+		{name: "unknown kid", signer: unknownSigner, claims: claims(nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.Validate(signToken(t, tt.signer, tt.claims))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Validate() accepted the token, claims %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			c, ok := got.(*Claims)
+			if !ok {
+				t.Fatalf("Validate() returned %T, want *Claims", got)
+			}
+			if c.Subject != "alice" || c.Issuer != testIssuer || !slices.Equal(c.Scopes, []string{"gerrit:read"}) {
+				t.Errorf("Validate() claims = %+v", c)
+			}
+		})
+	}
+}
+
+func TestJWTTokenValidatorRejectsMissingBearer(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := testSigningKey{kid: "ec-1", method: jwt.SigningMethodES256, key: ecKey}
+	validator, err := NewJWTTokenValidator("Authorization", writeJWKS(t, signer), testIssuer, testAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signToken(t, signer, jwt.MapClaims{"sub": "alice", "iss": testIssuer, "aud": testAudience, "exp": time.Now().Add(time.Hour).Unix()})
+	if _, err := validator.Validate(token[len("Bearer "):]); err == nil {
+		t.Error("Validate() accepted a token without the Bearer prefix")
+	}
+}
+
+func TestNewJWTTokenValidatorRequiresIssuerAndAudience(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := writeJWKS(t, testSigningKey{kid: "ec-1", method: jwt.SigningMethodES256, key: ecKey})
+	for _, tt := range []struct{ issuer, audience string }{
+		{"", ""},
+		{testIssuer, ""},
+		{"", testAudience},
+	} {
+		if _, err := NewJWTTokenValidator("Authorization", jwks, tt.issuer, tt.audience); err == nil {
+			t.Errorf("NewJWTTokenValidator(issuer %q, audience %q) accepted the configuration", tt.issuer, tt.audience)
+		}
+	}
+}
+
+func TestParseJWKSSkipsUnsupportedKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := testSigningKey{kid: "ec-1", method: jwt.SigningMethodES256, key: ecKey}
+	data, err := os.ReadFile(writeJWKS(t, signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set map[string][]map[string]string
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatal(err)
+	}
+	unsupported := []map[string]string{
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		{"kty": "EC", "kid": "secp256k1", "crv": "secp256k1", "x": "AA", "y": "AA"},
+		{"kty": "OKP", "kid": "x448", "crv": "X448", "x": "AA"},
+	}
+	set["keys"] = append(unsupported, set["keys"]...)
+	data, err = json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		t.Fatalf("parseJWKS() error = %v", err)
+	}
+	if len(keys) != 1 || keys["ec-1"] == nil {
+		t.Errorf("parseJWKS() keys = %v, want only ec-1", keys)
+	}
+
+	data, err = json.Marshal(map[string]any{"keys": unsupported})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseJWKS(data); err == nil {
+		t.Error("parseJWKS() accepted a set without a supported key")
+	}
+}
@@ -2,9 +2,9 @@ package middlewares
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
-
-	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"net/http"
 )
 
 type SimpleTokenValidator struct {
@@ -16,16 +16,16 @@ func (s *SimpleTokenValidator) IsDisabled() bool {
 	return s.Secret == ""
 }
 
-func (s *SimpleTokenValidator) Extract(ctx context.Context, req mcpserver.CallToolRequest) string {
-	reqHeaders := req.Header
-	return reqHeaders.Get(s.HeaderName)
+func (s *SimpleTokenValidator) Extract(ctx context.Context, header http.Header) string {
+	return header.Get(s.HeaderName)
 }
 
 func (s *SimpleTokenValidator) Validate(token string) (any, error) {
 	if s.Secret == "" {
 		return nil, nil
 	}
-	if token == fmt.Sprintf("Bearer %s", s.Secret) {
+	expected := []byte(fmt.Sprintf("Bearer %s", s.Secret))
+	if subtle.ConstantTimeCompare([]byte(token), expected) == 1 {
 		return "", nil
 	}
 	return nil, fmt.Errorf("invalid token")
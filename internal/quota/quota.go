@@ -0,0 +1,197 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const dayLayout = "2006-01-02"
+
+// Config limits each caller, zero disables the corresponding limit.
+type Config struct {
+	RequestsPerMinute int `yaml:"RequestsPerMinute"`
+	MaxConcurrent     int `yaml:"MaxConcurrent"`
+	DailyGerritCalls  int `yaml:"DailyGerritCalls"`
+}
+
+func (c Config) Enabled() bool {
+	return c.RequestsPerMinute > 0 || c.MaxConcurrent > 0 || c.DailyGerritCalls > 0
+}
+
+// LimitError is returned when a caller is over one of its limits.
+type LimitError struct {
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded, retry in %s", e.Limit, e.RetryAfter.Round(time.Second))
+}
+
+// Usage is the current consumption of a caller.
+type Usage struct {
+	Caller               string  `json:"caller"`
+	RequestsPerMinute    int     `json:"requests_per_minute_limit,omitempty"`
+	RequestsAvailable    float64 `json:"requests_available,omitempty"`
+	MaxConcurrent        int     `json:"max_concurrent_limit,omitempty"`
+	InFlight             int     `json:"in_flight"`
+	DailyGerritCalls     int     `json:"daily_gerrit_calls_limit,omitempty"`
+	GerritCallsToday     int     `json:"gerrit_calls_today"`
+	DailyBudgetResetsInS int64   `json:"daily_budget_resets_in_seconds"`
+}
+
+type callerState struct {
+	limiter     *rate.Limiter
+	inFlight    int
+	day         string
+	gerritCalls int
+	lastSeen    time.Time
+}
+
+// Limiter tracks per caller request rate, concurrency and daily Gerrit REST calls.
+type Limiter struct {
+	config Config
+
+	mu      sync.Mutex
+	callers map[string]*callerState
+	day     string
+}
+
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{config: config, callers: make(map[string]*callerState)}
+}
+
+func (l *Limiter) state(caller string, now time.Time) *callerState {
+	today := now.UTC().Format(dayLayout)
+	if today != l.day {
+		// forget callers idle since yesterday
+		for name, s := range l.callers {
+			if s.inFlight == 0 && now.Sub(s.lastSeen) > 24*time.Hour {
+				delete(l.callers, name)
+			}
+		}
+		l.day = today
+	}
+	s, ok := l.callers[caller]
+	if !ok {
+		s = &callerState{day: today}
+		if l.config.RequestsPerMinute > 0 {
+			perSecond := rate.Limit(float64(l.config.RequestsPerMinute) / 60)
+			s.limiter = rate.NewLimiter(perSecond, l.config.RequestsPerMinute)
+		}
+		l.callers[caller] = s
+	}
+	if s.day != today {
+		s.day = today
+		s.gerritCalls = 0
+	}
+	s.lastSeen = now
+	return s
+}
+
+// Acquire admits a tool call of caller. The returned release function must be
+// called once the call is over.
+func (l *Limiter) Acquire(caller string) (func(), error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.state(caller, now)
+	if l.config.DailyGerritCalls > 0 && s.gerritCalls >= l.config.DailyGerritCalls {
+		return nil, &LimitError{Limit: "daily Gerrit REST call budget", RetryAfter: untilTomorrow(now)}
+	}
+	if l.config.MaxConcurrent > 0 && s.inFlight >= l.config.MaxConcurrent {
+		return nil, &LimitError{Limit: "concurrent calls", RetryAfter: time.Second}
+	}
+	if s.limiter != nil {
+		reservation := s.limiter.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			return nil, &LimitError{Limit: "requests per minute", RetryAfter: delay}
+		}
+	}
+	s.inFlight++
+	return func() {
+		l.mu.Lock()
+		s.inFlight--
+		l.mu.Unlock()
+	}, nil
+}
+
+// CountGerritCall charges a Gerrit REST call to the daily budget of caller.
+func (l *Limiter) CountGerritCall(caller string) error {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.state(caller, now)
+	if l.config.DailyGerritCalls > 0 && s.gerritCalls >= l.config.DailyGerritCalls {
+		return &LimitError{Limit: "daily Gerrit REST call budget", RetryAfter: untilTomorrow(now)}
+	}
+	s.gerritCalls++
+	return nil
+}
+
+func (l *Limiter) Usage(caller string) Usage {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.state(caller, now)
+	usage := Usage{
+		Caller:               caller,
+		RequestsPerMinute:    l.config.RequestsPerMinute,
+		MaxConcurrent:        l.config.MaxConcurrent,
+		InFlight:             s.inFlight,
+		DailyGerritCalls:     l.config.DailyGerritCalls,
+		GerritCallsToday:     s.gerritCalls,
+		DailyBudgetResetsInS: int64(math.Ceil(untilTomorrow(now).Seconds())),
+	}
+	if s.limiter != nil {
+		usage.RequestsAvailable = math.Floor(s.limiter.TokensAt(now))
+	}
+	return usage
+}
+
+func untilTomorrow(now time.Time) time.Duration {
+	utc := now.UTC()
+	tomorrow := time.Date(utc.Year(), utc.Month(), utc.Day()+1, 0, 0, 0, 0, time.UTC)
+	return tomorrow.Sub(utc)
+}
+
+type callerKey struct{}
+
+// WithCaller binds the Gerrit REST calls made with ctx to caller.
+func WithCaller(ctx context.Context, limiter *Limiter, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, &boundCaller{limiter: limiter, caller: caller})
+}
+
+type boundCaller struct {
+	limiter *Limiter
+	caller  string
+}
+
+// Transport charges every Gerrit REST request to the caller bound to the
+// request context and refuses requests once the daily budget is spent.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if bound, ok := req.Context().Value(callerKey{}).(*boundCaller); ok {
+		if err := bound.limiter.CountGerritCall(bound.caller); err != nil {
+			return nil, err
+		}
+	}
+	return t.Base.RoundTrip(req)
+}
@@ -0,0 +1,127 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Marker replaces redacted secrets, followed by the name of the detector,
+// e.g. "[REDACTED:private-key]", so that readers know something was removed.
+const Marker = "[REDACTED"
+
+// Detector finds one kind of secret. When Pattern has a group named
+// "secret" only that group is replaced, which keeps the surrounding context
+// (a key name, a cookie domain) readable.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultDetectors cover the secrets commonly leaked in code, commit
+// messages and logs.
+var DefaultDetectors = []Detector{
+	{
+		Name:    "private-key",
+		Pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?(-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----|$)`),
+	},
+	{
+		// Netscape cookie file lines as found in .gitcookies
+		Name:    "gitcookie",
+		Pattern: regexp.MustCompile(`(?m)^#?(HttpOnly_)?[\w.-]+\t(TRUE|FALSE)\t\S+\t(TRUE|FALSE)\t\d+\t[^\t\n]+\t(?P<secret>[^\t\n]+)$`),
+	},
+	{
+		Name:    "jwt",
+		Pattern: regexp.MustCompile(`\beyJ[\w-]{8,}\.eyJ[\w-]{8,}\.[\w-]{8,}`),
+	},
+	{
+		Name:    "bearer-token",
+		Pattern: regexp.MustCompile(`(?i)\b(bearer|token)\s+(?P<secret>[\w\-.~+/]{16,}=*)`),
+	},
+	{
+		Name: "token",
+		Pattern: regexp.MustCompile(`\b(` +
+			`gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|` +
+			`glpat-[\w-]{20,}|` +
+			`xox[abposr]-[A-Za-z0-9-]{10,}|` +
+			`(AKIA|ASIA)[0-9A-Z]{16}|` +
+			`AIza[\w-]{35}|ya29\.[\w-]{20,}|1//0[\w-]{20,}|` +
+			`sk-[A-Za-z0-9_-]{20,}` +
+			`)`),
+	},
+	{
+		Name:    "url-credentials",
+		Pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:(?P<secret>[^/\s@]+)@`),
+	},
+	{
+		// quoted values of password-like keys: password = "...", "api_key": "..."
+		Name:    "password",
+		Pattern: regexp.MustCompile(`(?i)\b[\w-]*(password|passwd|pwd|secret|api[_-]?key|access[_-]?key|auth[_-]?token)["']?\s*[:=]\s*["'](?P<secret>[^"'\s]{4,})["']`),
+	},
+	{
+		// unquoted values of password-like keys filling the rest of the line,
+		// as in .env, properties and YAML files
+		Name:    "password",
+		Pattern: regexp.MustCompile(`(?im)^[\s-]*(export\s+)?[\w.-]*(password|passwd|pwd|secret|api[_-]?key|access[_-]?key|auth[_-]?token)\s*[:=]\s*(?P<secret>[^\s"'(){}\[\]]{4,})\s*$`),
+	},
+}
+
+// Redactor replaces the secrets found by its detectors.
+type Redactor struct {
+	detectors []Detector
+}
+
+func New(detectors ...Detector) *Redactor {
+	return &Redactor{detectors: detectors}
+}
+
+// Default is the Redactor using DefaultDetectors.
+var Default = New(DefaultDetectors...)
+
+// String returns s with every detected secret replaced by the marker.
+func (r *Redactor) String(s string) string {
+	for _, detector := range r.detectors {
+		s = detector.replace(s)
+	}
+	return s
+}
+
+// Redacted reports whether String would change s.
+func (r *Redactor) Redacted(s string) bool {
+	for _, detector := range r.detectors {
+		if detector.Pattern.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Detector) marker() string {
+	return fmt.Sprintf("%s:%s]", Marker, d.Name)
+}
+
+func (d Detector) replace(s string) string {
+	group := d.Pattern.SubexpIndex("secret")
+	matches := d.Pattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	marker := d.marker()
+	out := make([]byte, 0, len(s))
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if group > 0 && match[2*group] >= 0 {
+			start, end = match[2*group], match[2*group+1]
+		}
+		out = append(out, s[last:start]...)
+		out = append(out, marker...)
+		last = end
+	}
+	out = append(out, s[last:]...)
+	return string(out)
+}
+
+// String redacts s with the Default redactor.
+func String(s string) string {
+	return Default.String(s)
+}
@@ -0,0 +1,146 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"gerrit-mcp/internal/change"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	FileMaxTokens   = 300
+	ChangeMaxTokens = 500
+	// MaxFiles bounds the files of a change summarized one by one, the
+	// others keep their diffs. Changes built by change.NewGerritChange
+	// carry no more diffs than that.
+	MaxFiles = change.FileDiffsLimit
+	// maxDiffSize bounds the diff of a file sent to the model
+	maxDiffSize = 64 << 10
+
+	fileSystemPrompt = "You summarize code changes for a code reviewer. " +
+		"Answer with two or three plain sentences, without preamble."
+	changeSystemPrompt = "You summarize Gerrit changes for a code reviewer. " +
+		"Answer with a short paragraph saying what the change does and why, then its risks if any, without preamble."
+)
+
+// Request is a completion asked to the language model.
+type Request struct {
+	SystemPrompt string
+	Prompt       string
+	MaxTokens    int
+}
+
+// Sampler asks a language model for a completion. The MCP server implements
+// it with sampling/createMessage requests to the model of its client.
+type Sampler interface {
+	Sample(ctx context.Context, request Request) (string, error)
+}
+
+// SamplerFunc adapts a function, such as a stub, to Sampler.
+type SamplerFunc func(ctx context.Context, request Request) (string, error)
+
+func (f SamplerFunc) Sample(ctx context.Context, request Request) (string, error) {
+	return f(ctx, request)
+}
+
+type FileSummary struct {
+	Path string
+	// Summary is empty when the diff of the file was not fetched or the
+	// file is beyond MaxFiles
+	Summary string
+	// Diff is the diff of the files beyond MaxFiles
+	Diff string
+}
+
+type ChangeSummary struct {
+	URL     string
+	Subject string
+	Summary string
+	Files   []FileSummary
+}
+
+// Change summarizes the first MaxFiles files with a diff of a change, then the
+// change from its subject and the summaries of its files.
+func Change(ctx context.Context, sampler Sampler, c *change.GerritChange) (*ChangeSummary, error) {
+	result := &ChangeSummary{URL: c.URL, Subject: c.Subject}
+	summarized := 0
+	for _, path := range c.Paths {
+		diff, ok := c.DiffMap[path]
+		if !ok {
+			result.Files = append(result.Files, FileSummary{Path: path})
+			continue
+		}
+		if summarized >= MaxFiles {
+			result.Files = append(result.Files, FileSummary{Path: path, Diff: diff})
+			continue
+		}
+		if len(diff) > maxDiffSize {
+			diff = truncate(diff, maxDiffSize) + "\n[diff truncated]"
+		}
+		summary, err := sampler.Sample(ctx, Request{
+			SystemPrompt: fileSystemPrompt,
+			Prompt: fmt.Sprintf("Summarize the changes to %s in the change %q of %s. New content of the modified lines:\n\n%s",
+				path, c.Subject, c.Project, diff),
+			MaxTokens: FileMaxTokens,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize %s: %w", path, err)
+		}
+		result.Files = append(result.Files, FileSummary{Path: path, Summary: strings.TrimSpace(summary)})
+		summarized++
+	}
+
+	prompt := strings.Builder{}
+	prompt.WriteString(fmt.Sprintf("Summarize the change %q of %s from the summaries of its files:\n\n", c.Subject, c.Project))
+	for _, file := range result.Files {
+		prompt.WriteString(fmt.Sprintf("%s: %s\n", file.Path, fileSummaryText(file)))
+	}
+	summary, err := sampler.Sample(ctx, Request{
+		SystemPrompt: changeSystemPrompt,
+		Prompt:       prompt.String(),
+		MaxTokens:    ChangeMaxTokens,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize the change: %w", err)
+	}
+	result.Summary = strings.TrimSpace(summary)
+	return result, nil
+}
+
+// truncate cuts s to at most size bytes, without splitting a UTF-8 sequence.
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
+
+func fileSummaryText(file FileSummary) string {
+	switch {
+	case file.Summary != "":
+		return file.Summary
+	case file.Diff != "":
+		return fmt.Sprintf("(not summarized, beyond the first %d files)", MaxFiles)
+	}
+	return "(not summarized, the diff was not fetched)"
+}
+
+// Text renders the summary like change.GerritChange.TextResult, with the
+// summaries in place of the diffs.
+func (s *ChangeSummary) Text() string {
+	text := strings.Builder{}
+	text.WriteString(fmt.Sprintf("%s: %s\n", s.URL, s.Subject))
+	text.WriteString(fmt.Sprintf("Summary: %s\n", s.Summary))
+	for _, file := range s.Files {
+		if file.Summary == "" && file.Diff != "" {
+			text.WriteString(fmt.Sprintf("%s:\n%s\n", file.Path, file.Diff))
+			continue
+		}
+		text.WriteString(fmt.Sprintf("%s: %s\n", file.Path, fileSummaryText(file)))
+	}
+	return text.String()
+}
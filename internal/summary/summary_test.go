@@ -0,0 +1,111 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"gerrit-mcp/internal/change"
+	"github.com/andygrunwald/go-gerrit"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int
+		want string
+	}{
+		{s: "short", size: 10, want: "short"},
+		{s: "abcdef", size: 3, want: "abc"},
+		// é is two bytes, € three
+		{s: "aé", size: 2, want: "a"},
+		{s: "a€b", size: 2, want: "a"},
+		{s: "a€b", size: 3, want: "a"},
+		{s: "a€b", size: 4, want: "a€"},
+		{s: "€", size: 1, want: ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.size); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestChange(t *testing.T) {
+	c := &change.GerritChange{
+		Subject: "Rename the parser",
+		Project: "v8/v8",
+		URL:     "https://gerrit.example.com/c/v8/v8/+/1",
+		DiffMap: make(map[string]string),
+	}
+	for i := range MaxFiles + 2 {
+		path := fmt.Sprintf("src/file%02d.cc", i)
+		c.Paths = append(c.Paths, path)
+		c.DiffMap[path] = "+int parser" + path
+	}
+	// a diff over the limit, cut in the middle of a rune
+	c.DiffMap[c.Paths[0]] = strings.Repeat("a", maxDiffSize-1) + "é"
+	c.Paths = append(c.Paths, "not/fetched.cc")
+
+	var prompts []Request
+	sampler := SamplerFunc(func(ctx context.Context, request Request) (string, error) {
+		prompts = append(prompts, request)
+		return fmt.Sprintf(" summary %d \n", len(prompts)), nil
+	})
+	result, err := Change(context.Background(), sampler, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(prompts) != MaxFiles+1 {
+		t.Fatalf("sampled %d times, want %d files and the change", len(prompts), MaxFiles)
+	}
+	if first := prompts[0].Prompt; !utf8.ValidString(first) || !strings.HasSuffix(first, "a\n[diff truncated]") {
+		t.Errorf("prompt of the truncated diff ends with %q", first[len(first)-30:])
+	}
+	if result.Summary != fmt.Sprintf("summary %d", MaxFiles+1) || result.Files[1].Summary != "summary 2" {
+		t.Errorf("summaries are not trimmed: %q, %q", result.Summary, result.Files[1].Summary)
+	}
+	changePrompt := prompts[MaxFiles].Prompt
+	for _, want := range []string{
+		fmt.Sprintf("src/file%02d.cc: (not summarized, beyond the first %d files)", MaxFiles, MaxFiles),
+		"not/fetched.cc: (not summarized, the diff was not fetched)",
+	} {
+		if !strings.Contains(changePrompt, want) {
+			t.Errorf("change prompt lacks %q:\n%s", want, changePrompt)
+		}
+	}
+
+	text := result.Text()
+	beyond := c.Paths[MaxFiles]
+	if want := beyond + ":\n" + c.DiffMap[beyond] + "\n"; !strings.Contains(text, want) {
+		t.Errorf("text lacks the diff of %s:\n%s", beyond, text)
+	}
+	if !strings.Contains(text, c.Paths[1]+": summary 2\n") {
+		t.Errorf("text lacks the summary of %s:\n%s", c.Paths[1], text)
+	}
+}
+
+func TestChangeSummarizesRenamedFiles(t *testing.T) {
+	c, err := change.NewGerritChange(&gerrit.ChangeInfo{Subject: "Rename the parser", Project: "v8/v8"}, []*gerrit.DiffInfo{{
+		ChangeType: "RENAMED",
+		MetaA:      gerrit.DiffFileMetaInfo{Name: "src/old_parser.cc"},
+		MetaB:      gerrit.DiffFileMetaInfo{Name: "src/parser.cc"},
+		Content:    []gerrit.DiffContent{{B: []string{"+int parser"}}},
+	}}, "https://gerrit.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampler := SamplerFunc(func(ctx context.Context, request Request) (string, error) {
+		return "summary", nil
+	})
+	result, err := Change(context.Background(), sampler, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Path != "src/parser.cc" || result.Files[0].Summary != "summary" {
+		t.Errorf("Files = %+v, want the summary of src/parser.cc", result.Files)
+	}
+}
@@ -0,0 +1,248 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gerritclient "gerrit-mcp/internal/gerrit"
+	"gerrit-mcp/internal/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-gerrit"
+)
+
+const (
+	DefaultPollInterval = 30 * time.Second
+	// MaxChangesPerSession bounds the changes a single session may watch
+	MaxChangesPerSession = 100
+	// queryBatchSize is the number of changes checked by one Gerrit query
+	queryBatchSize = 50
+)
+
+var ErrTooManyWatches = fmt.Errorf("a session may watch at most %d changes", MaxChangesPerSession)
+
+// Notifier is called with each session and resource URI to notify when a
+// watched change was updated.
+type Notifier func(sessionID, uri string)
+
+type watchedChange struct {
+	updated time.Time
+	// subscribers maps session ids to the resource URIs they watch
+	subscribers map[string]map[string]bool
+}
+
+// Watcher tracks the changes watched by MCP sessions and finds out which
+// were updated, by polling Gerrit or through ChangeUpdated.
+type Watcher struct {
+	gerritClient *gerrit.Client
+	interval     time.Duration
+	notify       Notifier
+
+	mu       sync.Mutex
+	changes  map[int]*watchedChange
+	lastPoll time.Time
+}
+
+func New(gerritClient *gerrit.Client, interval time.Duration, notify Notifier) *Watcher {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &Watcher{
+		gerritClient: gerritClient,
+		interval:     interval,
+		notify:       notify,
+		changes:      make(map[int]*watchedChange),
+	}
+}
+
+// Watch subscribes a session to the updates of a change made after updated,
+// the last update time the session knows of. Updates are notified with uri.
+func (w *Watcher) Watch(sessionID string, number int, updated time.Time, uri string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	change, ok := w.changes[number]
+	if !ok || change.subscribers[sessionID] == nil {
+		if w.countSessionChanges(sessionID) >= MaxChangesPerSession {
+			return ErrTooManyWatches
+		}
+	}
+	if !ok {
+		change = &watchedChange{updated: updated, subscribers: make(map[string]map[string]bool)}
+		w.changes[number] = change
+	}
+	if change.subscribers[sessionID] == nil {
+		change.subscribers[sessionID] = make(map[string]bool)
+	}
+	change.subscribers[sessionID][uri] = true
+	return nil
+}
+
+func (w *Watcher) countSessionChanges(sessionID string) int {
+	count := 0
+	for _, change := range w.changes {
+		if change.subscribers[sessionID] != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Unwatch removes the subscription of a session to uri, or to every URI of
+// the change when uri is empty. It reports whether the session watched it.
+func (w *Watcher) Unwatch(sessionID string, number int, uri string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	change, ok := w.changes[number]
+	if !ok || change.subscribers[sessionID] == nil {
+		return false
+	}
+	found := true
+	if uri == "" {
+		delete(change.subscribers, sessionID)
+	} else {
+		found = change.subscribers[sessionID][uri]
+		delete(change.subscribers[sessionID], uri)
+		if len(change.subscribers[sessionID]) == 0 {
+			delete(change.subscribers, sessionID)
+		}
+	}
+	if len(change.subscribers) == 0 {
+		delete(w.changes, number)
+	}
+	return found
+}
+
+// RemoveSession drops every subscription of a closed session.
+func (w *Watcher) RemoveSession(sessionID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for number, change := range w.changes {
+		delete(change.subscribers, sessionID)
+		if len(change.subscribers) == 0 {
+			delete(w.changes, number)
+		}
+	}
+}
+
+// Watched returns the numbers of the changes watched by a session.
+func (w *Watcher) Watched(sessionID string) []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	numbers := make([]int, 0)
+	for number, change := range w.changes {
+		if change.subscribers[sessionID] != nil {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// Sessions returns the ids of the sessions watching a change.
+func (w *Watcher) Sessions(number int) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	change, ok := w.changes[number]
+	if !ok {
+		return nil
+	}
+	sessions := make([]string, 0, len(change.subscribers))
+	for sessionID := range change.subscribers {
+		sessions = append(sessions, sessionID)
+	}
+	sort.Strings(sessions)
+	return sessions
+}
+
+// ChangeUpdated notifies the subscribers of a change when updated is later
+// than the last update they were told about.
+func (w *Watcher) ChangeUpdated(number int, updated time.Time) {
+	type notification struct{ sessionID, uri string }
+	var notifications []notification
+	w.mu.Lock()
+	if change, ok := w.changes[number]; ok && updated.After(change.updated) {
+		change.updated = updated
+		for sessionID, uris := range change.subscribers {
+			for uri := range uris {
+				notifications = append(notifications, notification{sessionID, uri})
+			}
+		}
+	}
+	w.mu.Unlock()
+	for _, n := range notifications {
+		w.notify(n.sessionID, n.uri)
+	}
+}
+
+// Poll asks Gerrit which watched changes were updated since the previous
+// poll. Only changes updated recently are returned by the query, so a poll
+// of unchanged changes costs a single empty response per batch.
+func (w *Watcher) Poll(ctx context.Context) error {
+	w.mu.Lock()
+	numbers := make([]int, 0, len(w.changes))
+	for number := range w.changes {
+		numbers = append(numbers, number)
+	}
+	since := w.lastPoll
+	w.mu.Unlock()
+	if len(numbers) == 0 {
+		return nil
+	}
+	sort.Ints(numbers)
+	// cached change metadata would hide updates for up to its TTL
+	ctx = gerritclient.WithoutCache(ctx)
+
+	started := time.Now()
+	// Gerrit ages have a one second resolution and the clocks may drift, a
+	// margin of one interval avoids missing updates, which are deduplicated
+	// on their updated timestamp anyway
+	age := ""
+	if !since.IsZero() {
+		age = fmt.Sprintf(" -age:%ds", int((started.Sub(since) + w.interval).Seconds()))
+	}
+	var errs []error
+	for start := 0; start < len(numbers); start += queryBatchSize {
+		batch := numbers[start:min(start+queryBatchSize, len(numbers))]
+		terms := make([]string, 0, len(batch))
+		for _, number := range batch {
+			terms = append(terms, fmt.Sprintf("change:%d", number))
+		}
+		opt := &gerrit.QueryChangeOptions{}
+		opt.Query = []string{"(" + strings.Join(terms, " OR ") + ")" + age}
+		changes, _, err := w.gerritClient.Changes.QueryChanges(ctx, opt)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, change := range *changes {
+			w.ChangeUpdated(change.Number, change.Updated.Time)
+		}
+	}
+	if len(errs) > 0 {
+		// poll the same period again next time
+		return errors.Join(errs...)
+	}
+	w.mu.Lock()
+	w.lastPoll = started
+	w.mu.Unlock()
+	return nil
+}
+
+// Run polls Gerrit every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := w.Poll(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorf("Failed to poll watched changes: %v", err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gerritclient "gerrit-mcp/internal/gerrit"
+
+	"github.com/andygrunwald/go-gerrit"
+)
+
+var changeTerm = regexp.MustCompile(`change:(\d+)`)
+
+// fakeGerrit answers change queries with the updated timestamps of updates,
+// for the changes named in the query, and records the queries.
+type fakeGerrit struct {
+	mu      sync.Mutex
+	updates map[int]time.Time
+	queries []string
+}
+
+func (f *fakeGerrit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/changes/" {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query().Get("q")
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	changes := []gerrit.ChangeInfo{}
+	for _, match := range changeTerm.FindAllStringSubmatch(query, -1) {
+		number, _ := strconv.Atoi(match[1])
+		if updated, ok := f.updates[number]; ok {
+			changes = append(changes, gerrit.ChangeInfo{Number: number, Updated: gerrit.Timestamp{Time: updated}})
+		}
+	}
+	f.mu.Unlock()
+	data, err := json.Marshal(changes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(")]}'\n"))
+	w.Write(data)
+}
+
+func (f *fakeGerrit) setUpdated(number int, updated time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates[number] = updated
+}
+
+func (f *fakeGerrit) takeQueries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	queries := f.queries
+	f.queries = nil
+	return queries
+}
+
+type recorder struct {
+	mu            sync.Mutex
+	notifications []string
+}
+
+func (r *recorder) notify(sessionID, uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, sessionID+" "+uri)
+}
+
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notifications := r.notifications
+	r.notifications = nil
+	slices.Sort(notifications)
+	return notifications
+}
+
+func TestPoll(t *testing.T) {
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	fake := &fakeGerrit{updates: make(map[int]time.Time)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	// polls must not be answered from the cache of change metadata
+	transport, err := gerritclient.NewCachingTransport(nil, gerritclient.CacheConfig{Enabled: true, ChangesTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gerritClient, err := gerrit.NewClient(context.Background(), server.URL, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{}
+	w := New(gerritClient, time.Minute, r.notify)
+	const watched = queryBatchSize + 10
+	for number := 1; number <= watched; number++ {
+		if err := w.Watch("s1", number, base, fmt.Sprintf("gerrit://host/changes/%d", number)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Watch("s2", 7, base, "gerrit://host/changes/7"); err != nil {
+		t.Fatal(err)
+	}
+	// unchanged since the session read it
+	fake.setUpdated(3, base)
+	fake.setUpdated(7, base.Add(time.Minute))
+	fake.setUpdated(55, base.Add(time.Minute))
+
+	ctx := context.Background()
+	if err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	queries := fake.takeQueries()
+	if len(queries) != 2 {
+		t.Fatalf("first poll sent %d queries, want 2 batches: %q", len(queries), queries)
+	}
+	var queried []int
+	for _, query := range queries {
+		terms := changeTerm.FindAllStringSubmatch(query, -1)
+		if len(terms) > queryBatchSize {
+			t.Errorf("query of %d changes, want at most %d", len(terms), queryBatchSize)
+		}
+		for _, term := range terms {
+			number, _ := strconv.Atoi(term[1])
+			queried = append(queried, number)
+		}
+		if regexp.MustCompile(`-age:`).MatchString(query) {
+			t.Errorf("first poll query %q is restricted by age", query)
+		}
+	}
+	if len(queried) != watched {
+		t.Errorf("first poll queried %d changes, want %d", len(queried), watched)
+	}
+	want := []string{"s1 gerrit://host/changes/55", "s1 gerrit://host/changes/7", "s2 gerrit://host/changes/7"}
+	if got := r.take(); !slices.Equal(got, want) {
+		t.Errorf("first poll notified %q, want %q", got, want)
+	}
+
+	// the same updated timestamps are not notified twice
+	if err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	queries = fake.takeQueries()
+	if len(queries) != 2 {
+		t.Fatalf("second poll sent %d queries, want 2 uncached batches", len(queries))
+	}
+	for _, query := range queries {
+		if !regexp.MustCompile(`\) -age:\d+s$`).MatchString(query) {
+			t.Errorf("second poll query %q is not restricted by age", query)
+		}
+	}
+	if got := r.take(); len(got) != 0 {
+		t.Errorf("second poll notified %q again", got)
+	}
+
+	fake.setUpdated(55, base.Add(2*time.Minute))
+	if err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"s1 gerrit://host/changes/55"}
+	if got := r.take(); !slices.Equal(got, want) {
+		t.Errorf("third poll notified %q, want %q", got, want)
+	}
+}
+
+func TestWatchLimit(t *testing.T) {
+	w := New(nil, time.Minute, func(string, string) {})
+	for number := 1; number <= MaxChangesPerSession; number++ {
+		if err := w.Watch("s1", number, time.Time{}, "uri"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Watch("s1", MaxChangesPerSession+1, time.Time{}, "uri"); err != ErrTooManyWatches {
+		t.Errorf("Watch() beyond the limit error = %v, want %v", err, ErrTooManyWatches)
+	}
+	// another URI of a watched change does not count
+	if err := w.Watch("s1", 1, time.Time{}, "other"); err != nil {
+		t.Errorf("Watch() of a watched change error = %v", err)
+	}
+	if err := w.Watch("s2", MaxChangesPerSession+1, time.Time{}, "uri"); err != nil {
+		t.Errorf("Watch() of another session error = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"gerrit-mcp/internal/logger"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// readOnlyTool annotates a tool reading Gerrit without changing anything.
+// Tools only changing the state of the MCP session, such as watches, are
+// read-only too: Gerrit is their environment.
+func readOnlyTool(title string) mcp.ToolAnnotation {
+	return mcp.ToolAnnotation{
+		Title:           title,
+		ReadOnlyHint:    mcp.ToBoolPtr(true),
+		DestructiveHint: mcp.ToBoolPtr(false),
+		IdempotentHint:  mcp.ToBoolPtr(true),
+		OpenWorldHint:   mcp.ToBoolPtr(true),
+	}
+}
+
+// localTool annotates a read-only tool answering from the state of this
+// server, without calling Gerrit.
+func localTool(title string) mcp.ToolAnnotation {
+	annotation := readOnlyTool(title)
+	annotation.OpenWorldHint = mcp.ToBoolPtr(false)
+	return annotation
+}
+
+// mutatingTool annotates a tool changing Gerrit, e.g. posting a review.
+// Destructive tools delete or overwrite data, such as abandoning a change or
+// deleting a vote, and idempotent ones have no further effect when repeated
+// with the same arguments.
+func mutatingTool(title string, destructive, idempotent bool) mcp.ToolAnnotation {
+	return mcp.ToolAnnotation{
+		Title:           title,
+		ReadOnlyHint:    mcp.ToBoolPtr(false),
+		DestructiveHint: mcp.ToBoolPtr(destructive),
+		IdempotentHint:  mcp.ToBoolPtr(idempotent),
+		OpenWorldHint:   mcp.ToBoolPtr(true),
+	}
+}
+
+// isReadOnly reports whether a tool is annotated as read-only, tools without
+// annotations are assumed to mutate.
+func isReadOnly(tool mcp.Tool) bool {
+	return tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint
+}
+
+// allowTool reports whether the tool may be registered, mutating tools are
+// refused in read-only mode.
+func (s *Server) allowTool(tool mcp.Tool) bool {
+	if s.config.ReadOnly && !isReadOnly(tool) {
+		logger.Infof("Read-only mode, not registering the mutating tool %s", tool.Name)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestReadOnlyDropsMutatingTools(t *testing.T) {
+	const mutatingToolName = "abandon_change"
+	for _, readOnly := range []bool{false, true} {
+		s := NewServer(WithConfig(Config{ReadOnly: readOnly}))
+		s.addTool(mcp.NewTool(mutatingToolName, mcp.WithDescription("Abandons a change.")),
+			mutatingTool("Abandon change", true, true),
+			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				return mcp.NewToolResultText("abandoned"), nil
+			})
+
+		mcpClient := newTestClient(t, serveTestServer(t, s), nil)
+		result, err := mcpClient.ListTools(context.Background(), mcp.ListToolsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed := false
+		for _, tool := range result.Tools {
+			if tool.Name == mutatingToolName {
+				listed = true
+			} else if !isReadOnly(tool) {
+				t.Errorf("built-in tool %s is not annotated as read-only", tool.Name)
+			}
+		}
+		if listed == readOnly {
+			t.Errorf("ReadOnly = %t, %s listed = %t", readOnly, mutatingToolName, listed)
+		}
+		if _, registered := s.tools[mutatingToolName]; registered == readOnly {
+			t.Errorf("ReadOnly = %t, %s registered = %t", readOnly, mutatingToolName, registered)
+		}
+	}
+}
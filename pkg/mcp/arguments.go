@@ -0,0 +1,199 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// The arguments of the tools. Their input schema is generated by toolSchema:
+// the jsonschema tag of a field is the description of the property, and the
+// enum, minimum and maximum tags constrain its values. Optional properties
+// are omitempty, their zero value standing for the default.
+
+type queryChangesByFilterArgs struct {
+	Status    string  `json:"status,omitempty" jsonschema:"Status of the change, open by default" enum:"open,merged,abandoned,closed"`
+	Limit     int     `json:"limit,omitempty" jsonschema:"Number of changes to return, every change by default" minimum:"1" maximum:"500"`
+	Project   string  `json:"project,omitempty" jsonschema:"Project name"`
+	Age       float64 `json:"age,omitempty" jsonschema:"Age of the change in hours, fractions of an hour are rounded to the minute" minimum:"1"`
+	Summarize bool    `json:"summarize,omitempty" jsonschema:"Return summaries of the files and of the changes instead of their diffs, when the client supports sampling"`
+}
+
+type queryProjectsArgs struct {
+	Prefix string `json:"prefix,omitempty" jsonschema:"Project name prefix"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"Number of projects to return, every project by default" minimum:"1" maximum:"500"`
+}
+
+type queryChangeArgs struct {
+	ReviewURL string `json:"reviewURL,omitempty" jsonschema:"Review URL"`
+	TrackID   int    `json:"trackID,omitempty" jsonschema:"track ID (crbug ID in case of chromium)" minimum:"1"`
+	Summarize bool   `json:"summarize,omitempty" jsonschema:"Return summaries of the files and of the change instead of their diffs, when the client supports sampling"`
+}
+
+// changeArgs designates a change by its review URL or its number.
+type changeArgs struct {
+	ReviewURL string `json:"reviewURL,omitempty" jsonschema:"Review URL"`
+	Number    int    `json:"number,omitempty" jsonschema:"Change number" minimum:"1"`
+}
+
+type listRecentEventsArgs struct {
+	Project string `json:"project,omitempty" jsonschema:"Project name"`
+	Change  int    `json:"change,omitempty" jsonschema:"Change number" minimum:"1"`
+	Type    string `json:"type,omitempty" jsonschema:"Event type, e.g. patchset-created, comment-added or change-merged"`
+	Limit   int    `json:"limit,omitempty" jsonschema:"Number of events to return, 20 by default" minimum:"1" maximum:"1000"`
+}
+
+type noArgs struct{}
+
+// toolSchema generates the JSON Schema of the arguments T. It panics on
+// invalid tags, which are programming errors caught at startup.
+func toolSchema[T any]() json.RawMessage {
+	schema, err := jsonschema.For[T](nil)
+	if err != nil {
+		panic(err)
+	}
+	fields := reflect.TypeFor[T]()
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		property := schema.Properties[name]
+		if property == nil {
+			continue
+		}
+		if enum, ok := field.Tag.Lookup("enum"); ok {
+			for _, value := range strings.Split(enum, ",") {
+				property.Enum = append(property.Enum, value)
+			}
+		}
+		property.Minimum = schemaBound(field, "minimum")
+		property.Maximum = schemaBound(field, "maximum")
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func schemaBound(field reflect.StructField, tag string) *float64 {
+	value, ok := field.Tag.Lookup(tag)
+	if !ok {
+		return nil
+	}
+	bound, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s tag of %s: %v", tag, field.Name, err))
+	}
+	return &bound
+}
+
+// typedTool binds the arguments of a call to T before calling handler, and
+// returns the binding errors to the model so that it can correct its call.
+func typedTool[T any](handler func(context.Context, mcp.CallToolRequest, T) (*mcp.CallToolResult, error)) ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var args T
+		if err := bindArguments(request, &args); err != nil {
+			return newToolErrorResult(ErrorCodeInvalidArguments,
+				fmt.Sprintf("invalid arguments for tool %s: %v", request.Params.Name, err),
+				map[string]any{"tool": request.Params.Name}), nil
+		}
+		return handler(ctx, request, args)
+	}
+}
+
+// bindArguments decodes the arguments of a call into target, a pointer to an
+// argument struct, and checks them against the constraints of its schema.
+func bindArguments(request mcp.CallToolRequest, target any) error {
+	data, err := json.Marshal(request.Params.Arguments)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%s must be %s, got %s", typeErr.Field, schemaTypeName(typeErr.Type), typeErr.Value)
+		}
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("unknown argument %s", field)
+		}
+		return err
+	}
+
+	present := request.GetArguments()
+	value := reflect.ValueOf(target).Elem()
+	var errs []error
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if _, ok := present[name]; !ok {
+			continue
+		}
+		if err := checkArgument(name, field, value.Field(i)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkArgument checks a decoded argument against the enum, minimum and
+// maximum tags of its field.
+func checkArgument(name string, field reflect.StructField, value reflect.Value) error {
+	if enum, ok := field.Tag.Lookup("enum"); ok {
+		if !slices.Contains(strings.Split(enum, ","), value.String()) {
+			return fmt.Errorf("%s must be one of %s, got %q", name, strings.ReplaceAll(enum, ",", ", "), value.String())
+		}
+	}
+	var number float64
+	switch value.Kind() {
+	case reflect.Int:
+		number = float64(value.Int())
+	case reflect.Float64:
+		number = value.Float()
+	default:
+		return nil
+	}
+	minimum, maximum := schemaBound(field, "minimum"), schemaBound(field, "maximum")
+	switch {
+	case minimum != nil && maximum != nil && (number < *minimum || number > *maximum):
+		return fmt.Errorf("%s must be between %v and %v, got %v", name, *minimum, *maximum, number)
+	case minimum != nil && number < *minimum:
+		return fmt.Errorf("%s must be at least %v, got %v", name, *minimum, number)
+	case maximum != nil && number > *maximum:
+		return fmt.Errorf("%s must be at most %v, got %v", name, *maximum, number)
+	}
+	return nil
+}
+
+func schemaTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int:
+		return "an integer"
+	case reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	}
+	return t.String()
+}
+
+// formatAge formats an age in hours for the age: search operator, which
+// only takes integers.
+func formatAge(hours float64) string {
+	if hours == float64(int(hours)) {
+		return fmt.Sprintf("%dh", int(hours))
+	}
+	return fmt.Sprintf("%dm", max(1, int(hours*60+0.5)))
+}
@@ -0,0 +1,196 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+func TestToolSchemas(t *testing.T) {
+	schemas := map[string]json.RawMessage{
+		"query_changes_by_filter": toolSchema[queryChangesByFilterArgs](),
+		"query_projects":          toolSchema[queryProjectsArgs](),
+		"query_change":            toolSchema[queryChangeArgs](),
+		"change":                  toolSchema[changeArgs](),
+		"list_recent_events":      toolSchema[listRecentEventsArgs](),
+		"no_args":                 toolSchema[noArgs](),
+	}
+	for name, schema := range schemas {
+		t.Run(name, func(t *testing.T) {
+			got := bytes.Buffer{}
+			if err := json.Indent(&got, schema, "", "  "); err != nil {
+				t.Fatal(err)
+			}
+			got.WriteString("\n")
+			path := filepath.Join("testdata", name+".schema.golden")
+			if *update {
+				if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run go test -update to create it", err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("schema of %s changed, run go test -update if intended:\n%s\nwant:\n%s", name, got.String(), want)
+			}
+		})
+	}
+}
+
+func TestBindArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    any
+		arguments map[string]any
+		want      any
+		wantErr   string
+	}{
+		{
+			name:      "valid",
+			target:    &queryChangesByFilterArgs{},
+			arguments: map[string]any{"status": "merged", "limit": 10, "project": "v8/v8", "age": 1.5, "summarize": true},
+			want:      &queryChangesByFilterArgs{Status: "merged", Limit: 10, Project: "v8/v8", Age: 1.5, Summarize: true},
+		},
+		{
+			name:      "omitted arguments keep their zero value",
+			target:    &queryChangesByFilterArgs{},
+			arguments: map[string]any{},
+			want:      &queryChangesByFilterArgs{},
+		},
+		{
+			name:      "bad enum value",
+			target:    &queryChangesByFilterArgs{},
+			arguments: map[string]any{"status": "draft"},
+			wantErr:   `status must be one of open, merged, abandoned, closed, got "draft"`,
+		},
+		{
+			name:      "empty enum value",
+			target:    &queryChangesByFilterArgs{},
+			arguments: map[string]any{"status": ""},
+			wantErr:   `status must be one of open, merged, abandoned, closed, got ""`,
+		},
+		{
+			name:      "below the minimum",
+			target:    &queryProjectsArgs{},
+			arguments: map[string]any{"limit": 0},
+			wantErr:   "limit must be between 1 and 500, got 0",
+		},
+		{
+			name:      "above the maximum",
+			target:    &listRecentEventsArgs{},
+			arguments: map[string]any{"limit": 1001},
+			wantErr:   "limit must be between 1 and 1000, got 1001",
+		},
+		{
+			name:      "negative number",
+			target:    &queryChangesByFilterArgs{},
+			arguments: map[string]any{"age": -2},
+			wantErr:   "age must be at least 1, got -2",
+		},
+		{
+			// an explicit zero would be taken for the default age
+			name:      "zero age",
+			target:    &queryChangesByFilterArgs{},
+			arguments: map[string]any{"age": 0},
+			wantErr:   "age must be at least 1, got 0",
+		},
+		{
+			name:      "only a minimum",
+			target:    &changeArgs{},
+			arguments: map[string]any{"number": -1},
+			wantErr:   "number must be at least 1, got -1",
+		},
+		{
+			name:      "string instead of an integer",
+			target:    &queryChangeArgs{},
+			arguments: map[string]any{"trackID": "123"},
+			wantErr:   "trackID must be an integer, got string",
+		},
+		{
+			name:      "fraction instead of an integer",
+			target:    &changeArgs{},
+			arguments: map[string]any{"number": 1.5},
+			wantErr:   "number must be an integer, got number 1.5",
+		},
+		{
+			name:      "string instead of a boolean",
+			target:    &queryChangeArgs{},
+			arguments: map[string]any{"summarize": "yes"},
+			wantErr:   "summarize must be a boolean, got string",
+		},
+		{
+			name:      "number instead of a string",
+			target:    &queryProjectsArgs{},
+			arguments: map[string]any{"prefix": 42},
+			wantErr:   "prefix must be a string, got number",
+		},
+		{
+			name:      "unknown field",
+			target:    &queryProjectsArgs{},
+			arguments: map[string]any{"prefix": "chromium", "owner": "alice"},
+			wantErr:   `unknown argument "owner"`,
+		},
+		{
+			name:      "any field of no arguments",
+			target:    &noArgs{},
+			arguments: map[string]any{"verbose": true},
+			wantErr:   `unknown argument "verbose"`,
+		},
+		{
+			name:      "every violation",
+			target:    &queryChangesByFilterArgs{},
+			arguments: map[string]any{"status": "draft", "limit": 501},
+			wantErr:   `status must be one of open, merged, abandoned, closed, got "draft"` + "\n" + "limit must be between 1 and 500, got 501",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := mcp.CallToolRequest{}
+			request.Params.Arguments = tt.arguments
+			err := bindArguments(request, tt.target)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("bindArguments() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bindArguments() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.target, tt.want) {
+				t.Errorf("bindArguments() = %+v, want %+v", tt.target, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypedToolReturnsBindingErrors(t *testing.T) {
+	called := false
+	handler := typedTool(func(ctx context.Context, request mcp.CallToolRequest, args queryProjectsArgs) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("ok"), nil
+	})
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "query_projects"
+	request.Params.Arguments = map[string]any{"limit": "ten"}
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if called || !result.IsError || !strings.Contains(resultText(result), "invalid arguments for tool query_projects: limit must be an integer") {
+		t.Errorf("typedTool() = %+v, handler called = %t", result, called)
+	}
+}
@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"gerrit-mcp/internal/audit"
+	"gerrit-mcp/internal/logger"
+	"time"
+
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	AuditStatusOK    = "ok"
+	AuditStatusError = "error"
+)
+
+// AuditMiddleware writes an audit record for every tool call, resource read,
+// prompt and completion request, the MCP methods calling Gerrit.
+type AuditMiddleware struct {
+	auditLogger *audit.Logger
+}
+
+func NewAuditMiddleware(auditLogger *audit.Logger) *AuditMiddleware {
+	return &AuditMiddleware{auditLogger: auditLogger}
+}
+
+// audit calls next and writes record, completed with the outcome of the call
+// and the Gerrit calls it made.
+func (m *AuditMiddleware) audit(ctx context.Context, record audit.Record, next func(context.Context) error) {
+	ctx, collector := audit.WithCollector(ctx)
+	start := time.Now()
+	err := next(ctx)
+
+	record.Time = start.UTC()
+	record.Caller = callerFromContext(ctx)
+	record.Status = AuditStatusOK
+	record.LatencyMS = time.Since(start).Milliseconds()
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		record.Session = session.SessionID()
+	}
+	collector.Fill(&record)
+	if err != nil {
+		record.Status = AuditStatusError
+		record.Error = err.Error()
+	}
+	if logErr := m.auditLogger.Log(record); logErr != nil {
+		logger.FromContext(ctx).Errorf("unable to write audit record: %v", logErr)
+	}
+}
+
+func (m *AuditMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (result *mcpserver.CallToolResult, err error) {
+			record := audit.Record{
+				Method:    string(mcpserver.MethodToolsCall),
+				Tool:      req.Params.Name,
+				Arguments: audit.RedactArguments(req.GetArguments()),
+			}
+			m.audit(ctx, record, func(ctx context.Context) error {
+				result, err = next(ctx, req)
+				if err == nil && result != nil && result.IsError {
+					return errors.New(resultErrorMessage(result))
+				}
+				return err
+			})
+			return result, err
+		}
+	}
+}
+
+func (m *AuditMiddleware) ResourceMiddleware() server.ResourceHandlerMiddleware {
+	return func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+		return func(ctx context.Context, req mcpserver.ReadResourceRequest) (contents []mcpserver.ResourceContents, err error) {
+			record := audit.Record{Method: string(mcpserver.MethodResourcesRead), Resource: req.Params.URI}
+			m.audit(ctx, record, func(ctx context.Context) error {
+				contents, err = next(ctx, req)
+				return err
+			})
+			return contents, err
+		}
+	}
+}
+
+func (m *AuditMiddleware) PromptMiddleware() server.PromptHandlerMiddleware {
+	return func(next server.PromptHandlerFunc) server.PromptHandlerFunc {
+		return func(ctx context.Context, req mcpserver.GetPromptRequest) (result *mcpserver.GetPromptResult, err error) {
+			arguments := make(map[string]any, len(req.Params.Arguments))
+			for name, value := range req.Params.Arguments {
+				arguments[name] = value
+			}
+			record := audit.Record{
+				Method:    string(mcpserver.MethodPromptsGet),
+				Prompt:    req.Params.Name,
+				Arguments: audit.RedactArguments(arguments),
+			}
+			m.audit(ctx, record, func(ctx context.Context) error {
+				result, err = next(ctx, req)
+				return err
+			})
+			return result, err
+		}
+	}
+}
+
+func (m *AuditMiddleware) CompletionMiddleware() completionMiddleware {
+	return func(next completionHandlerFunc) completionHandlerFunc {
+		return func(ctx context.Context, ref completionRef, argument mcpserver.CompleteArgument, completeContext mcpserver.CompleteContext) (result *mcpserver.Completion, err error) {
+			record := audit.Record{
+				Method:    string(mcpserver.MethodCompletionComplete),
+				Prompt:    ref.prompt,
+				Resource:  ref.uri,
+				Arguments: audit.RedactArguments(map[string]any{argument.Name: argument.Value}),
+			}
+			m.audit(ctx, record, func(ctx context.Context) error {
+				result, err = next(ctx, ref, argument, completeContext)
+				return err
+			})
+			return result, err
+		}
+	}
+}
+
+// callerFromContext names the authenticated caller of a request.
+func callerFromContext(ctx context.Context) string {
+	if claims := ClaimsFromContext(ctx); claims != nil {
+		return claims.Subject
+	}
+	if ctx.Value(ScopesContextKey) != nil {
+		return "bearer-token"
+	}
+	return "anonymous"
+}
+
+func resultErrorMessage(result *mcpserver.CallToolResult) string {
+	if toolErr, ok := result.StructuredContent.(ToolError); ok {
+		return toolErr.Code + ": " + toolErr.Message
+	}
+	for _, content := range result.Content {
+		if text, ok := mcpserver.AsTextContent(content); ok {
+			return text.Text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/middlewares"
+
+	"github.com/andygrunwald/go-gerrit"
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	ScopeChangesRead  = "changes:read"
+	ScopeChangesWrite = "changes:write"
+	ScopeProjectsRead = "projects:read"
+	// ScopeAdmin allows changing the log level at runtime
+	ScopeAdmin = "admin"
+)
+
+// AuthzMiddleware checks that the token claims stored by AuthMiddleware grant
+// the scopes required by the called tool. Tokens without claims (static
+// bearer secret or disabled authentication) are not restricted.
+type AuthzMiddleware struct {
+	toolScopes map[string][]string
+}
+
+func NewAuthzMiddleware() *AuthzMiddleware {
+	return &AuthzMiddleware{toolScopes: make(map[string][]string)}
+}
+
+func (m *AuthzMiddleware) RequireScopes(toolName string, scopes ...string) {
+	m.toolScopes[toolName] = scopes
+}
+
+func (m *AuthzMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
+			claims := ClaimsFromContext(ctx)
+			if claims == nil {
+				return next(ctx, req)
+			}
+			if !claims.AllowsTool(req.Params.Name) {
+				logger.FromContext(ctx).Infof("denied tool %s: not in the allowed tools of the token", req.Params.Name)
+				return newToolErrorResult(ErrorCodeForbidden,
+					fmt.Sprintf("token is not allowed to call tool %s", req.Params.Name),
+					map[string]any{"tool": req.Params.Name}), nil
+			}
+			required, ok := m.toolScopes[req.Params.Name]
+			if !ok {
+				return nil, fmt.Errorf("no scopes configured for tool %s", req.Params.Name)
+			}
+			if missing := missingScopes(claims, required); len(missing) > 0 {
+				logger.FromContext(ctx).Infof("denied tool %s: missing scopes %v", req.Params.Name, missing)
+				return newToolErrorResult(ErrorCodeInsufficientScope,
+					fmt.Sprintf("token lacks scopes required by tool %s", req.Params.Name),
+					map[string]any{"tool": req.Params.Name, "required_scopes": required, "missing_scopes": missing}), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// requireScopes returns an error naming the scopes the token in ctx lacks.
+// Tokens without claims, such as the shared secret, are not scoped.
+func requireScopes(ctx context.Context, scopes ...string) error {
+	claims := ClaimsFromContext(ctx)
+	if claims == nil {
+		return nil
+	}
+	if missing := missingScopes(claims, scopes); len(missing) > 0 {
+		return fmt.Errorf("%s: token lacks scopes %v", ErrorCodeInsufficientScope, missing)
+	}
+	return nil
+}
+
+func missingScopes(claims *middlewares.Claims, required []string) []string {
+	missing := make([]string, 0)
+	for _, scope := range required {
+		if !claims.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// ClaimsFromContext returns the claims of the validated token, or nil when
+// the token carries none.
+func ClaimsFromContext(ctx context.Context) *middlewares.Claims {
+	claims, _ := ctx.Value(ScopesContextKey).(*middlewares.Claims)
+	return claims
+}
+
+func projectAllowed(ctx context.Context, project string) bool {
+	claims := ClaimsFromContext(ctx)
+	return claims == nil || claims.AllowsProject(project)
+}
+
+// allowedChanges drops the changes of projects the token may not access.
+func allowedChanges(ctx context.Context, changes []gerrit.ChangeInfo) []gerrit.ChangeInfo {
+	allowed := make([]gerrit.ChangeInfo, 0, len(changes))
+	for _, c := range changes {
+		if projectAllowed(ctx, c.Project) {
+			allowed = append(allowed, c)
+		}
+	}
+	return allowed
+}
+
+func projectForbiddenResult(project string) *mcpserver.CallToolResult {
+	return newToolErrorResult(ErrorCodeForbidden,
+		fmt.Sprintf("token is not allowed to access project %s", project),
+		map[string]any{"project": project})
+}
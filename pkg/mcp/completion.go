@@ -0,0 +1,271 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gerrit-mcp/internal/cache"
+	"gerrit-mcp/internal/logger"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// CompletionCacheTTL is how long the Gerrit lookups backing completions
+	// are kept, clients ask for completions on every keystroke
+	CompletionCacheTTL   = 5 * time.Minute
+	completionCacheBytes = 4 << 20
+	// maxCompletionValues is the limit set by the MCP specification
+	maxCompletionValues = 100
+	// accounts are only suggested from this many characters on
+	minAccountQueryLength = 2
+)
+
+// completer completes prompt and resource template arguments by name:
+// projects, branches and labels of the project given in the other
+// arguments, and accounts. Values the caller may not access are left out.
+type completer struct {
+	gerritClient *gerrit.Client
+	store        cache.Store
+}
+
+func newCompleter(gerritClient *gerrit.Client) *completer {
+	return &completer{
+		gerritClient: gerritClient,
+		store:        cache.NewMemory(completionCacheBytes),
+	}
+}
+
+// completionRef is the prompt or the resource template of a completed
+// argument.
+type completionRef struct {
+	prompt string
+	uri    string
+}
+
+// completionHandlerFunc completes an argument. mcp-go has no middlewares for
+// completions, completionMiddleware wraps the handler like the tool, resource
+// and prompt middlewares do.
+type completionHandlerFunc func(ctx context.Context, ref completionRef, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error)
+
+type completionMiddleware func(completionHandlerFunc) completionHandlerFunc
+
+// completionProvider serves completion/complete requests through the
+// completion middlewares, the first one being the outermost.
+type completionProvider struct {
+	handler completionHandlerFunc
+}
+
+func newCompletionProvider(handler completionHandlerFunc, middlewares ...completionMiddleware) *completionProvider {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return &completionProvider{handler: handler}
+}
+
+func (p *completionProvider) CompletePromptArgument(ctx context.Context, promptName string, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	return p.handler(ctx, completionRef{prompt: promptName}, argument, completeContext)
+}
+
+func (p *completionProvider) CompleteResourceArgument(ctx context.Context, uri string, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	return p.handler(ctx, completionRef{uri: uri}, argument, completeContext)
+}
+
+func (c *completer) handleCompletion(ctx context.Context, ref completionRef, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	if ref.uri != "" {
+		return c.CompleteResourceArgument(ctx, ref.uri, argument, completeContext)
+	}
+	return c.CompletePromptArgument(ctx, ref.prompt, argument, completeContext)
+}
+
+func (c *completer) CompletePromptArgument(ctx context.Context, promptName string, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	return c.complete(ctx, argument, completeContext.Arguments)
+}
+
+func (c *completer) CompleteResourceArgument(ctx context.Context, uri string, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	switch {
+	case argument.Name == "host":
+		baseURL := c.gerritClient.BaseURL()
+		return completion(filterPrefix([]string{baseURL.Host}, argument.Value)), nil
+	case uri == ProjectResourceTemplate && argument.Name == "name":
+		argument.Name = "project"
+	}
+	return c.complete(ctx, argument, completeContext.Arguments)
+}
+
+func (c *completer) complete(ctx context.Context, argument mcp.CompleteArgument, arguments map[string]string) (*mcp.Completion, error) {
+	var values []string
+	var err error
+	switch argument.Name {
+	case "project":
+		if requireScopes(ctx, ScopeProjectsRead) != nil {
+			break
+		}
+		values, err = c.projects(ctx, argument.Value)
+		values = filterAllowedProjects(ctx, filterPrefix(values, argument.Value))
+	case "branch":
+		project := arguments["project"]
+		if project == "" || requireScopes(ctx, ScopeProjectsRead) != nil || !projectAllowed(ctx, project) {
+			break
+		}
+		values, err = c.branches(ctx, project)
+		values = filterPrefix(values, argument.Value)
+	case "label":
+		project := arguments["project"]
+		if project == "" || requireScopes(ctx, ScopeProjectsRead) != nil || !projectAllowed(ctx, project) {
+			break
+		}
+		values, err = c.labels(ctx, project)
+		values = filterPrefix(values, argument.Value)
+	case "owner", "reviewer", "account":
+		if len(argument.Value) < minAccountQueryLength || requireScopes(ctx, ScopeChangesRead) != nil {
+			break
+		}
+		values, err = c.accounts(ctx, argument.Value)
+	}
+	if err != nil {
+		// completions are a convenience, the client can carry on without them
+		logger.FromContext(ctx).Warnf("Failed to complete %s %q: %v", argument.Name, argument.Value, err)
+		values = nil
+	}
+	return completion(values), nil
+}
+
+func completion(values []string) *mcp.Completion {
+	result := &mcp.Completion{Values: values, Total: len(values)}
+	if result.Values == nil {
+		result.Values = []string{}
+	}
+	if len(values) > maxCompletionValues {
+		result.Values = values[:maxCompletionValues]
+		result.HasMore = true
+	}
+	return result
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	filtered := make([]string, 0, len(values))
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			filtered = append(filtered, value)
+		}
+	}
+	return filtered
+}
+
+func filterAllowedProjects(ctx context.Context, projects []string) []string {
+	allowed := make([]string, 0, len(projects))
+	for _, project := range projects {
+		if projectAllowed(ctx, project) {
+			allowed = append(allowed, project)
+		}
+	}
+	return allowed
+}
+
+// cached returns the values stored under key, or looks them up and stores
+// them.
+func (c *completer) cached(key string, lookup func() ([]string, error)) ([]string, error) {
+	if data, ok := c.store.Get(key); ok {
+		var values []string
+		if err := json.Unmarshal(data, &values); err == nil {
+			return values, nil
+		}
+	}
+	values, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(values)
+	if data, err := json.Marshal(values); err == nil {
+		c.store.Set(key, data, CompletionCacheTTL)
+	}
+	return values, nil
+}
+
+func (c *completer) projects(ctx context.Context, prefix string) ([]string, error) {
+	return c.cached("projects:"+prefix, func() ([]string, error) {
+		opt := &gerrit.ProjectOptions{ProjectBaseOptions: gerrit.ProjectBaseOptions{Limit: maxCompletionValues + 1}}
+		opt.Prefix = prefix
+		projects, _, err := c.gerritClient.Projects.ListProjects(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(*projects))
+		for name := range *projects {
+			names = append(names, name)
+		}
+		return names, nil
+	})
+}
+
+func (c *completer) branches(ctx context.Context, project string) ([]string, error) {
+	return c.cached("branches:"+project, func() ([]string, error) {
+		branches, _, err := c.gerritClient.Projects.ListBranches(ctx, project, &gerrit.BranchOptions{})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(*branches))
+		for _, branch := range *branches {
+			if name, ok := strings.CutPrefix(branch.Ref, "refs/heads/"); ok {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	})
+}
+
+// labels returns the labels defined in the project config, including the
+// inherited ones.
+func (c *completer) labels(ctx context.Context, project string) ([]string, error) {
+	return c.cached("labels:"+project, func() ([]string, error) {
+		// go-gerrit's ProjectInfo lacks the labels field
+		req, err := c.gerritClient.NewRequest(ctx, "GET", fmt.Sprintf("projects/%s", url.QueryEscape(project)), nil)
+		if err != nil {
+			return nil, err
+		}
+		var info struct {
+			Labels map[string]json.RawMessage `json:"labels"`
+		}
+		if _, err := c.gerritClient.Do(req, &info); err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(info.Labels))
+		for name := range info.Labels {
+			names = append(names, name)
+		}
+		return names, nil
+	})
+}
+
+// accounts returns the emails, or usernames, of the accounts suggested by
+// Gerrit for a name or email prefix.
+func (c *completer) accounts(ctx context.Context, query string) ([]string, error) {
+	return c.cached("accounts:"+query, func() ([]string, error) {
+		// the suggest parameter has no value, which go-gerrit options cannot express
+		req, err := c.gerritClient.NewRequest(ctx, "GET",
+			fmt.Sprintf("accounts/?suggest&q=%s&n=%d", url.QueryEscape(query), maxCompletionValues), nil)
+		if err != nil {
+			return nil, err
+		}
+		var accounts []gerrit.AccountInfo
+		if _, err := c.gerritClient.Do(req, &accounts); err != nil {
+			return nil, err
+		}
+		values := make([]string, 0, len(accounts))
+		for _, account := range accounts {
+			switch {
+			case account.Email != "":
+				values = append(values, account.Email)
+			case account.Username != "":
+				values = append(values, account.Username)
+			}
+		}
+		return values, nil
+	})
+}
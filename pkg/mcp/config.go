@@ -1,15 +1,82 @@
 package mcp
 
 import (
+	"gerrit-mcp/internal/audit"
+	"gerrit-mcp/internal/certs"
+	"gerrit-mcp/internal/events"
+	gerritclient "gerrit-mcp/internal/gerrit"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/middlewares"
+	"gerrit-mcp/internal/quota"
+	"gerrit-mcp/internal/tracing"
+	"net/url"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	GerritInstance string `yaml:"GerritInstance"`
 	AuthHeaderName string `yaml:"AuthHeaderName"`
 	AuthSecret     string `yaml:"AuthSecret"`
 	UseSSE         bool   `yaml:"UseSSE"`
+	// JWKS is a path or an URL of the key set used to verify JWT bearer tokens.
+	// When set, tokens are validated as OAuth 2.0 / OIDC access tokens instead of AuthSecret.
+	JWKS        string `yaml:"JWKS"`
+	JWTIssuer   string `yaml:"JWTIssuer"`
+	JWTAudience string `yaml:"JWTAudience"`
+	// APIKeysFile is a keyfile of named, hashed API keys accepted as bearer tokens.
+	APIKeysFile string `yaml:"APIKeysFile"`
+	// TLS enables HTTPS and optionally client certificate authentication.
+	TLS certs.Config `yaml:"TLS"`
+	// ClientIdentities grants scopes to verified client certificates.
+	ClientIdentities []middlewares.CertIdentity `yaml:"ClientIdentities"`
+	// RedactSecrets replaces secrets found in tool outputs, such as
+	// credentials leaked in diffs, with a redaction marker.
+	RedactSecrets bool `yaml:"RedactSecrets"`
+	// ReadOnly refuses to register the tools changing Gerrit, only tools
+	// annotated as read-only are offered.
+	ReadOnly bool `yaml:"ReadOnly"`
+	// Log configures the format, level and output of the process logs.
+	Log logger.Config `yaml:"Log"`
+	// Audit configures the audit log of tool calls, disabled when Output is empty.
+	Audit audit.Config `yaml:"Audit"`
+	// Cache configures caching of Gerrit REST responses.
+	Cache gerritclient.CacheConfig `yaml:"Cache"`
+	// MetricsPath is where Prometheus metrics are served, empty disables them.
+	MetricsPath string `yaml:"MetricsPath"`
+	// Tracing configures the OpenTelemetry exporter, tracing is disabled without one.
+	Tracing tracing.Config `yaml:"Tracing"`
+	// Quota limits every caller (API key, token subject or session).
+	Quota quota.Config `yaml:"Quota"`
+	// ShutdownGracePeriod is how long in-flight tool calls may run after a
+	// shutdown is requested before they are cancelled.
+	ShutdownGracePeriod time.Duration `yaml:"ShutdownGracePeriod"`
+	// WatchPollInterval is how often the changes watched by sessions are
+	// checked for updates.
+	WatchPollInterval time.Duration `yaml:"WatchPollInterval"`
+	// Events configures the source of Gerrit events, watched changes are
+	// polled when it is not set.
+	Events events.Config `yaml:"Events"`
+	// Instances holds per Gerrit instance settings keyed by host name.
+	Instances map[string]InstanceConfig `yaml:"Instances"`
+}
+
+type InstanceConfig struct {
+	Policy gerritclient.PolicyConfig `yaml:"Policy"`
+	// Tools enables and disables tools on the instance, API keys and
+	// certificate identities can restrict them further.
+	Tools ToolsConfig `yaml:"Tools"`
+}
+
+// Instance returns the settings of the Gerrit instance at instanceURL.
+func (c Config) Instance(instanceURL string) InstanceConfig {
+	u, err := url.Parse(instanceURL)
+	if err != nil {
+		return InstanceConfig{}
+	}
+	return c.Instances[u.Hostname()]
 }
 
 func NewConfigFromFile(configPath string) (Config, error) {
@@ -20,6 +87,15 @@ func NewConfigFromFile(configPath string) (Config, error) {
 	return NewConfig(data)
 }
 
+// LoadConfigFile overrides the fields of config present in the file.
+func LoadConfigFile(configPath string, config *Config) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, config)
+}
+
 func NewConfig(data []byte) (Config, error) {
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
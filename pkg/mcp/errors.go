@@ -0,0 +1,30 @@
+package mcp
+
+import (
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	ErrorCodeInsufficientScope = "insufficient_scope"
+	ErrorCodeForbidden         = "forbidden"
+	ErrorCodeRateLimited       = "rate_limited"
+	ErrorCodeInvalidArguments  = "invalid_arguments"
+)
+
+// ToolError is returned as structured content of a failed tool call, so
+// clients can tell policy denials from Gerrit failures.
+type ToolError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func newToolErrorResult(code string, message string, details map[string]any) *mcpserver.CallToolResult {
+	return &mcpserver.CallToolResult{
+		Content: []mcpserver.Content{
+			mcpserver.NewTextContent(message),
+		},
+		StructuredContent: ToolError{Code: code, Message: message, Details: details},
+		IsError:           true,
+	}
+}
@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"gerrit-mcp/internal/audit"
+	"gerrit-mcp/internal/events"
+	"gerrit-mcp/internal/logger"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// eventLoggerName is the logger of the notifications/message carrying
+	// Gerrit events
+	eventLoggerName = "gerrit"
+
+	listRecentEventsToolName = "list_recent_events"
+	recentEventsDefaultLimit = 20
+)
+
+// WithEventReader reads Gerrit events from reader instead of polling the
+// watched changes.
+func WithEventReader(reader events.Reader) ServerOption {
+	return func(s *Server) {
+		s.eventReader = reader
+	}
+}
+
+// handleGerritEvent forwards the events about a watched change to the
+// sessions watching it: the change resource is notified as updated, and the
+// event itself is sent as a log message.
+func (s *Server) handleGerritEvent(event events.Event) {
+	if event.Change == 0 {
+		return
+	}
+	sessions := s.watcher.Sessions(event.Change)
+	if len(sessions) == 0 {
+		return
+	}
+	// event times have a one second resolution, round them up so that the
+	// poll after a reconnection does not notify the same update again
+	s.watcher.ChangeUpdated(event.Change, event.Time.Add(time.Second-time.Nanosecond))
+	event.Comment = s.redactPrompt(event.Comment)
+	for _, sessionID := range sessions {
+		err := s.mcpServer.SendNotificationToSpecificClient(sessionID, string(mcp.MethodNotificationMessage), map[string]any{
+			"level":  mcp.LoggingLevelInfo,
+			"logger": eventLoggerName,
+			"data":   event,
+		})
+		if err != nil {
+			logger.Debugf("Failed to send the %s event of change %d to session %s: %v", event.Type, event.Change, sessionID, err)
+		}
+	}
+}
+
+// runEvents ingests the Gerrit events until ctx is done. Watched changes are
+// polled once after every connection to the event source to catch up with
+// the updates missed while disconnected.
+func (s *Server) runEvents(ctx context.Context) {
+	events.Run(ctx, s.eventReader, s.events, func(ctx context.Context) {
+		if err := s.watcher.Poll(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorf("Failed to poll watched changes: %v", err)
+		}
+	})
+	if ctx.Err() == nil {
+		logger.Infof("Gerrit event source exhausted, polling watched changes")
+		s.watcher.Run(ctx)
+	}
+}
+
+// eventsEnabled reports whether Gerrit events are received at all.
+func (s *Server) eventsEnabled() bool {
+	return s.eventReader != nil || s.config.Events.Webhook.Enabled()
+}
+
+func (s *Server) handleListRecentEvents(ctx context.Context, request mcp.CallToolRequest, args listRecentEventsArgs) (*mcp.CallToolResult, error) {
+	project := args.Project
+	change := args.Change
+	eventType := args.Type
+	limit := cmp.Or(args.Limit, recentEventsDefaultLimit)
+
+	recent := s.recentEvents.Recent(limit, func(event events.Event) bool {
+		return (project == "" || event.Project == project) &&
+			(change == 0 || event.Change == change) &&
+			(eventType == "" || event.Type == eventType) &&
+			projectAllowed(ctx, event.Project)
+	})
+	numbers := make([]int, 0, len(recent))
+	for i := range recent {
+		recent[i].Comment = s.redactPrompt(recent[i].Comment)
+		if recent[i].Change != 0 {
+			numbers = append(numbers, recent[i].Change)
+		}
+	}
+	audit.AddChanges(ctx, numbers...)
+	data, err := json.MarshalIndent(recent, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultStructured(map[string]any{"events": recent}, string(data)), nil
+}
@@ -0,0 +1,120 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"gerrit-mcp/internal/events"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestReplayEvents(t *testing.T) {
+	// the watched change was last updated before the replayed events
+	watchedUpdated := time.Unix(1700000000, 0).UTC()
+	gerritClient := newTestGerritClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/changes/" || r.URL.Query().Get("q") != "change:1001" {
+			http.NotFound(w, r)
+			return
+		}
+		writeGerritJSON(t, w, []gerrit.ChangeInfo{{
+			Number: 1001, Project: "chromium/src", Subject: "Fix the flaky test",
+			Updated: gerrit.Timestamp{Time: watchedUpdated},
+		}})
+	}), nil)
+	s := NewServer(WithGerritClient(gerritClient))
+	var published []events.Event
+	s.events.Subscribe(func(event events.Event) {
+		published = append(published, event)
+	})
+
+	mcpClient := newTestClient(t, serveTestServer(t, s), nil)
+	updated := make(chan string, 10)
+	messages := make(chan map[string]any, 10)
+	mcpClient.OnNotification(func(notification mcp.JSONRPCNotification) {
+		switch notification.Method {
+		case string(mcp.MethodNotificationResourceUpdated):
+			updated <- fmt.Sprint(notification.Params.AdditionalFields["uri"])
+		case string(mcp.MethodNotificationMessage):
+			messages <- notification.Params.AdditionalFields
+		}
+	})
+	if result := callTool(t, mcpClient, watchChangeToolName, map[string]any{"number": 1001}); result.IsError {
+		t.Fatalf("%s = %+v", watchChangeToolName, result)
+	}
+
+	events.Run(context.Background(), &events.FileReader{Path: "testdata/stream-events.jsonl"}, s.events, nil)
+
+	want := []events.Event{
+		{
+			Type: events.TypePatchSetCreated, Time: time.Unix(1700000100, 0).UTC(),
+			Project: "chromium/src", Branch: "main", Change: 1001, Subject: "Fix the flaky test",
+			URL: "https://gerrit.example.com/c/chromium/src/+/1001", PatchSet: 2,
+			Actor: "Alice <alice@example.com>",
+		},
+		{
+			Type: events.TypeCommentAdded, Time: time.Unix(1700000200, 0).UTC(),
+			Project: "chromium/src", Branch: "main", Change: 1001, Subject: "Fix the flaky test",
+			URL: "https://gerrit.example.com/c/chromium/src/+/1001", PatchSet: 2,
+			Actor: "Bob <bob@example.com>", Comment: "Patch Set 2: Code-Review+2 Verified+1\n\nLGTM",
+			Approvals: map[string]string{"Code-Review": "2", "Verified": "1"},
+		},
+		{
+			Type: events.TypeChangeMerged, Time: time.Unix(1700000300, 0).UTC(),
+			Project: "v8/v8", Branch: "main", Change: 1002, Subject: "Speed up the parser",
+			URL: "https://gerrit.example.com/c/v8/v8/+/1002", PatchSet: 5, Actor: "Carol",
+		},
+		{
+			Type: events.TypeRefUpdated, Time: time.Unix(1700000400, 0).UTC(),
+			Project: "v8/v8", Actor: "carol", Ref: "refs/heads/main",
+		},
+	}
+	if len(published) != len(want) {
+		t.Fatalf("published %d events, want %d: %+v", len(published), len(want), published)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(published[i], want[i]) {
+			t.Errorf("event %d = %+v, want %+v", i, published[i], want[i])
+		}
+	}
+
+	// each event of the watched change is an update, the others are not
+	uri := s.changeURI(1001)
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-updated:
+			if got != uri {
+				t.Errorf("notified the update of %s, want %s", got, uri)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d updates of %s, want 2", i, uri)
+		}
+	}
+	select {
+	case got := <-updated:
+		t.Errorf("notified another update of %s", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case message := <-messages:
+			data, _ := message["data"].(map[string]any)
+			if message["logger"] != eventLoggerName || data["change"] != float64(1001) {
+				t.Errorf("event message = %v", message)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d event messages, want the 2 of change 1001", i)
+		}
+	}
+	select {
+	case message := <-messages:
+		t.Errorf("received another event message %v", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-gerrit"
+)
+
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+	InfoPath    = "/info"
+	// how long a readiness probe result is reused before Gerrit is asked again
+	ReadinessCacheTTL = 15 * time.Second
+	readinessTimeout  = 5 * time.Second
+
+	TransportSSE            = "sse"
+	TransportStreamableHTTP = "streamable-http"
+)
+
+// Readiness is the result of a readiness probe of the Gerrit instance.
+type Readiness struct {
+	Ready         bool      `json:"ready"`
+	GerritVersion string    `json:"gerrit_version,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// ServerInfo describes the running server on /info.
+type ServerInfo struct {
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	Transport string   `json:"transport"`
+	Tools     []string `json:"tools"`
+	// Instances are the host names of the configured Gerrit instances.
+	Instances []string `json:"instances"`
+}
+
+// readinessProbe checks that Gerrit is reachable and accepts the configured
+// credential by fetching its version, the result is cached for ttl.
+type readinessProbe struct {
+	client *gerrit.Client
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	last *Readiness
+}
+
+func newReadinessProbe(client *gerrit.Client, ttl time.Duration) *readinessProbe {
+	return &readinessProbe{client: client, ttl: ttl}
+}
+
+// check probes Gerrit on a context detached from the request, so that a
+// probe client going away does not cancel the check other probes share.
+func (p *readinessProbe) check(ctx context.Context) Readiness {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.last != nil && time.Since(p.last.CheckedAt) < p.ttl {
+		return *p.last
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readinessTimeout)
+	defer cancel()
+	result := Readiness{CheckedAt: time.Now()}
+	version, resp, err := p.client.Config.GetVersion(ctx)
+	if err != nil && resp != nil {
+		// go-gerrit leaves the body of error responses open
+		resp.Body.Close()
+	}
+	switch {
+	case resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden):
+		result.Error = fmt.Sprintf("Gerrit rejected the credential: %s", resp.Status)
+	case err != nil:
+		result.Error = fmt.Sprintf("Gerrit is unreachable: %v", err)
+	default:
+		result.Ready = true
+		result.GerritVersion = version
+	}
+	// a canceled check says nothing about Gerrit, probe again next time
+	if !errors.Is(err, context.Canceled) {
+		p.last = &result
+	}
+	return result
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	readiness := s.readiness.check(r.Context())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Info())
+}
+
+// Info returns the server version, the tools offered on the Gerrit instance,
+// the configured Gerrit instances and the MCP transport.
+func (s *Server) Info() ServerInfo {
+	info := ServerInfo{
+		Name:      ServerName,
+		Version:   ServerVersion,
+		Transport: TransportStreamableHTTP,
+		Tools:     []string{},
+	}
+	if s.config.UseSSE {
+		info.Transport = TransportSSE
+	}
+	info.Tools = append(info.Tools, s.toolNames()...)
+	baseURL := s.gerritClient.BaseURL()
+	instances := map[string]bool{baseURL.Hostname(): true}
+	for host := range s.config.Instances {
+		instances[host] = true
+	}
+	for host := range instances {
+		info.Instances = append(info.Instances, host)
+	}
+	sort.Strings(info.Instances)
+	return info
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
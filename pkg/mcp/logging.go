@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"context"
+	"gerrit-mcp/internal/logger"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	RequestIDHeader = "X-Request-Id"
+	LogLevelPath    = "/admin/log-level"
+	// longest request id accepted from a client
+	maxRequestIDLength = 128
+)
+
+// requestIDMiddleware adds the request id, taken from the X-Request-Id
+// header or generated, to the context logger and to the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logger.WithFields(r.Context(), "request_id", requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// LoggingMiddleware adds the session id and the tool name to the context
+// logger of tool calls and logs their outcome.
+type LoggingMiddleware struct{}
+
+func NewLoggingMiddleware() *LoggingMiddleware {
+	return &LoggingMiddleware{}
+}
+
+func (m *LoggingMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
+			if session := server.ClientSessionFromContext(ctx); session != nil {
+				ctx = logger.WithFields(ctx, "session", session.SessionID())
+			}
+			ctx = logger.WithFields(ctx, "tool", req.Params.Name)
+			start := time.Now()
+			result, err := next(ctx, req)
+			log := logger.FromContext(ctx)
+			switch {
+			case err != nil:
+				log.Errorw("tool call failed", "duration", time.Since(start), "error", err)
+			case result != nil && result.IsError:
+				log.Infow("tool call returned an error", "duration", time.Since(start), "error", resultErrorMessage(result))
+			default:
+				log.Debugw("tool call done", "duration", time.Since(start))
+			}
+			return result, err
+		}
+	}
+}
+
+// handleLogLevel exposes logger.LevelHandler to callers with the admin scope.
+// Callers without claims, such as holders of the static bearer token, have
+// no scopes and are refused.
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	if claims := ClaimsFromContext(r.Context()); claims == nil || !claims.HasScope(ScopeAdmin) {
+		http.Error(w, "the admin scope is required", http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodPut {
+		logger.FromContext(r.Context()).Infof("log level change requested by %s", callerFromContext(r.Context()))
+	}
+	logger.LevelHandler().ServeHTTP(w, r)
+}
@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"context"
+	"gerrit-mcp/internal/metrics"
+	"time"
+
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const metricsErrorCodeInternal = "internal"
+
+// MetricsMiddleware records tool call counts, latency and errors.
+type MetricsMiddleware struct{}
+
+func NewMetricsMiddleware() *MetricsMiddleware {
+	return &MetricsMiddleware{}
+}
+
+func (m *MetricsMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
+			tool := req.Params.Name
+			start := time.Now()
+			result, err := next(ctx, req)
+			metrics.ToolDuration.WithLabelValues(tool).Observe(time.Since(start).Seconds())
+
+			status := AuditStatusOK
+			switch {
+			case err != nil:
+				status = AuditStatusError
+				metrics.ToolErrors.WithLabelValues(tool, metricsErrorCodeInternal).Inc()
+			case result != nil && result.IsError:
+				status = AuditStatusError
+				code := metricsErrorCodeInternal
+				if toolErr, ok := result.StructuredContent.(ToolError); ok {
+					code = toolErr.Code
+				}
+				metrics.ToolErrors.WithLabelValues(tool, code).Inc()
+			}
+			metrics.ToolCalls.WithLabelValues(tool, status).Inc()
+			return result, err
+		}
+	}
+}
+
+// metricsHooks keeps the active sessions gauge up to date.
+func metricsHooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		metrics.ActiveSessions.Inc()
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		metrics.ActiveSessions.Dec()
+	})
+	return hooks
+}
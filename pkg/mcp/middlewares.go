@@ -2,8 +2,11 @@ package mcp
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/middlewares"
+	"net/http"
 
 	mcpserver "github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -11,39 +14,139 @@ import (
 
 type ToolHandlerFunc = server.ToolHandlerFunc
 
+// promptRecovery turns the panics of prompt handlers into errors, like
+// server.WithRecovery does for tools, mcp-go has no such option for prompts.
+func promptRecovery() server.PromptHandlerMiddleware {
+	return func(next server.PromptHandlerFunc) server.PromptHandlerFunc {
+		return func(ctx context.Context, req mcpserver.GetPromptRequest) (result *mcpserver.GetPromptResult, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.FromContext(ctx).Errorf("panic in the %s prompt handler: %v", req.Params.Name, r)
+					err = fmt.Errorf("panic recovered in %s prompt handler: %v", req.Params.Name, r)
+				}
+			}()
+			return next(ctx, req)
+		}
+	}
+}
+
+type contextKey string
+
+// ScopesContextKey holds the value returned by TokenValidator.Validate.
+const ScopesContextKey contextKey = "scopes"
+
+const authRealm = ServerName
+
 type TokenValidator interface {
-	Extract(ctx context.Context, req mcpserver.CallToolRequest) string
+	Extract(ctx context.Context, header http.Header) string
 	Validate(token string) (any, error)
 	IsDisabled() bool
 }
 
 type AuthMiddleware struct {
 	tokenValidator TokenValidator
+	// certValidator authenticates requests without a bearer token by their
+	// verified TLS client certificate, nil when mTLS is not configured
+	certValidator *middlewares.CertificateValidator
 }
 
-func NewAuthMiddleware(validator TokenValidator) *AuthMiddleware {
+func NewAuthMiddleware(validator TokenValidator, certValidator *middlewares.CertificateValidator) *AuthMiddleware {
 	if validator.IsDisabled() {
 		logger.Infof("auth token validation disabled")
 	}
-	return &AuthMiddleware{tokenValidator: validator}
+	return &AuthMiddleware{tokenValidator: validator, certValidator: certValidator}
+}
+
+// authenticate validates the token from the headers and returns ctx carrying
+// its scopes.
+func (m *AuthMiddleware) authenticate(ctx context.Context, header http.Header) (context.Context, error) {
+	token := m.tokenValidator.Extract(ctx, header)
+	if token == "" {
+		return ctx, fmt.Errorf("authentication required")
+	}
+	// Validate token
+	tokenScopes, err := m.tokenValidator.Validate(token)
+	if err != nil {
+		return ctx, fmt.Errorf("invalid token: %w", err)
+	}
+
+	return withScopes(ctx, tokenScopes), nil
+}
+
+// authenticateRequest authenticates r by its bearer token or, when it has
+// none, by its verified client certificate.
+func (m *AuthMiddleware) authenticateRequest(r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	if m.certValidator != nil && m.tokenValidator.Extract(ctx, r.Header) == "" {
+		if cert := verifiedClientCertificate(r); cert != nil {
+			claims, err := m.certValidator.Validate(cert)
+			if err != nil {
+				return ctx, err
+			}
+			return withScopes(ctx, claims), nil
+		}
+	}
+	if m.tokenValidator.IsDisabled() {
+		if m.certValidator != nil {
+			// with optional client certificates and no token validation,
+			// the certificate is the only credential
+			return ctx, fmt.Errorf("client certificate required")
+		}
+		return ctx, nil
+	}
+	return m.authenticate(ctx, r.Header)
 }
 
+func verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// withScopes returns ctx carrying the scopes of the authenticated caller.
+func withScopes(ctx context.Context, scopes any) context.Context {
+	ctx = context.WithValue(ctx, ScopesContextKey, scopes)
+	if claims := ClaimsFromContext(ctx); claims != nil {
+		ctx = logger.WithFields(ctx, "caller", claims.Subject)
+	}
+	return ctx
+}
+
+// HTTPMiddleware rejects unauthenticated HTTP requests with 401 before they
+// reach the MCP transport, so every MCP method is guarded, not only tool calls.
+func (m *AuthMiddleware) HTTPMiddleware(next http.Handler) http.Handler {
+	if m.tokenValidator.IsDisabled() && m.certValidator == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := m.authenticateRequest(r)
+		if err != nil {
+			logger.FromContext(r.Context()).Infof("rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+			if m.tokenValidator.Extract(r.Context(), r.Header) != "" {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ToolMiddleware authenticates tool calls that did not go through
+// HTTPMiddleware.
 func (m *AuthMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
 	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
 		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
-			token := m.tokenValidator.Extract(ctx, req)
-			if token == "" {
-				return nil, fmt.Errorf("authentication required")
+			if m.tokenValidator.IsDisabled() || ctx.Value(ScopesContextKey) != nil {
+				return next(ctx, req)
 			}
-			// Validate token
-			tokenScopes, err := m.tokenValidator.Validate(token)
+			ctx, err := m.authenticate(ctx, req.Header)
 			if err != nil {
-				return nil, fmt.Errorf("invalid token: %w", err)
+				return nil, err
 			}
-
-			// Add user to context
-			ctx = context.WithValue(ctx, "scopes", tokenScopes)
-
 			return next(ctx, req)
 		}
 	}
@@ -0,0 +1,257 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"gerrit-mcp/internal/change"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/redact"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	promptChangeURLArgument = "change_url"
+
+	triagePromptName = "triage_review_queue"
+	// triageChangeLimit bounds the changes listed in the triage prompt
+	triageChangeLimit = 25
+)
+
+// reviewPrompt is a prompt template run against a single change.
+type reviewPrompt struct {
+	name        string
+	description string
+	// instructions are the task given to the model, the change follows them
+	instructions string
+	// withComments adds the comment threads and change messages
+	withComments bool
+}
+
+var reviewPrompts = []reviewPrompt{
+	{
+		name:        "review_change",
+		description: "Review a change like an experienced reviewer of the project",
+		instructions: `Review the Gerrit change below as an experienced reviewer of this project.
+Look for bugs, unhandled edge cases, missing tests, unclear naming and deviations from the conventions of the surrounding code.
+For each finding give the file and line, how severe it is (blocking, should fix, nit) and a concrete suggestion.
+Do not repeat points already raised in the existing comments.
+Finish with the Code-Review vote you would give (-2 to +2) and a one sentence justification.`,
+		withComments: true,
+	},
+	{
+		name:        "summarize_change",
+		description: "Summarize what a change does and why",
+		instructions: `Summarize the Gerrit change below for someone who has not read it.
+Explain in a few sentences what it changes and why, then list the notable changes per file or component as short bullet points.
+Mention anything risky or worth a closer look, such as behavior changes, migrations or new dependencies.`,
+	},
+	{
+		name:        "security_review",
+		description: "Review a change for security issues",
+		instructions: `Perform a security review of the Gerrit change below.
+Look for injection, broken authentication or authorization, unsafe memory handling, race conditions, path traversal, unsafe deserialization, weak cryptography, leaked secrets and missing input validation.
+For each finding give the file and line, the CWE identifier when one applies, the severity (critical, high, medium, low), how it could be exploited and how to fix it.
+Say explicitly if you found no security issue.`,
+	},
+	{
+		name:        "explain_review_feedback",
+		description: "Explain the feedback reviewers left on a change",
+		instructions: `Explain the review feedback left on the Gerrit change below to its author.
+Group the comments by topic, and for each unresolved thread say what the reviewer is asking for and how it could be addressed in the code.
+Point out the votes that block submission, if any.`,
+		withComments: true,
+	},
+	{
+		name:        "draft_reply_to_comments",
+		description: "Draft replies to the unresolved comments of a change",
+		instructions: `Draft a reply to each unresolved comment thread of the Gerrit change below, as its author.
+Quote the file and line of the thread, then the reply.
+Reply "Done" only when the current patch set addresses the comment, otherwise explain the intent of the code or propose the follow-up change.
+Keep the replies short and polite.`,
+		withComments: true,
+	},
+}
+
+// addPrompts registers the code review prompts. Each takes a change URL and
+// embeds the change, its diff and, where relevant, its comments.
+func (s *Server) addPrompts() {
+	for _, p := range reviewPrompts {
+		s.mcpServer.AddPrompt(
+			mcp.NewPrompt(p.name,
+				mcp.WithPromptDescription(p.description),
+				mcp.WithArgument(promptChangeURLArgument,
+					mcp.ArgumentDescription("Review URL of the change, e.g. https://chromium-review.googlesource.com/c/chromium/src/+/1234567"),
+					mcp.RequiredArgument(),
+				),
+			),
+			s.promptHandler(p),
+		)
+	}
+	s.mcpServer.AddPrompt(
+		mcp.NewPrompt(triagePromptName,
+			mcp.WithPromptDescription("Prioritize the open changes of a project waiting for review"),
+			mcp.WithArgument("project", mcp.ArgumentDescription("Project name"), mcp.RequiredArgument()),
+			mcp.WithArgument("branch", mcp.ArgumentDescription("Only changes targeting this branch")),
+			mcp.WithArgument("owner", mcp.ArgumentDescription("Only changes owned by this account (email or username)")),
+			mcp.WithArgument("label", mcp.ArgumentDescription("Only changes not yet approved on this label, e.g. Code-Review")),
+		),
+		s.handleTriagePrompt,
+	)
+}
+
+func (s *Server) promptHandler(p reviewPrompt) func(context.Context, mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		ctx = logger.WithFields(ctx, "prompt", p.name)
+		reviewURL := request.Params.Arguments[promptChangeURLArgument]
+		if reviewURL == "" {
+			return nil, fmt.Errorf("%s is required", promptChangeURLArgument)
+		}
+		found, err := s.promptChange(ctx, reviewURL)
+		if err != nil {
+			return nil, err
+		}
+		gerritChanges, err := change.BuildGerritChanges(ctx, s.gerritClient, &[]gerrit.ChangeInfo{*found})
+		if err != nil {
+			return nil, err
+		}
+		baseURL := s.gerritClient.BaseURL()
+		changeURI := fmt.Sprintf("gerrit://%s/changes/%d", baseURL.Host, found.Number)
+
+		text := strings.Builder{}
+		for _, gc := range gerritChanges {
+			text.WriteString(gc.TextResult())
+		}
+		messages := []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(p.instructions)),
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+				URI: changeURI, MIMEType: MIMETypeText, Text: s.redactPrompt(text.String()),
+			})),
+		}
+		if p.withComments {
+			threads, err := change.BuildCommentThreads(ctx, s.gerritClient, found.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list comments of change %d: %w", found.Number, err)
+			}
+			feedback := "Change messages:\n"
+			for _, gc := range gerritChanges {
+				feedback += gc.MessagesText()
+			}
+			feedback += "\nInline comments:\n" + change.CommentThreadsText(threads)
+			messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+				URI: changeURI + "/comments", MIMEType: MIMETypeText, Text: s.redactPrompt(feedback),
+			})))
+		}
+		return mcp.NewGetPromptResult(fmt.Sprintf("%s for change %d: %s", p.name, found.Number, found.Subject), messages), nil
+	}
+}
+
+func (s *Server) handleTriagePrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	ctx = logger.WithFields(ctx, "prompt", triagePromptName)
+	arguments := request.Params.Arguments
+	project := arguments["project"]
+	if project == "" {
+		return nil, fmt.Errorf("project is required")
+	}
+	if err := requireScopes(ctx, ScopeChangesRead); err != nil {
+		return nil, fmt.Errorf("%w required to read changes", err)
+	}
+	if !projectAllowed(ctx, project) {
+		return nil, fmt.Errorf("%s: token is not allowed to access project %s", ErrorCodeForbidden, project)
+	}
+
+	for _, name := range []string{"project", "branch", "owner", "label"} {
+		if err := checkQueryValue(name, arguments[name]); err != nil {
+			return nil, err
+		}
+	}
+	query := []string{"status:open", "project:" + project}
+	if branch := arguments["branch"]; branch != "" {
+		query = append(query, "branch:"+branch)
+	}
+	if owner := arguments["owner"]; owner != "" {
+		query = append(query, "owner:"+owner)
+	}
+	if label := arguments["label"]; label != "" {
+		query = append(query, fmt.Sprintf("-label:%s=MAX", label))
+	}
+	opt := &gerrit.QueryChangeOptions{}
+	opt.Query = []string{strings.Join(query, " ")}
+	opt.Limit = triageChangeLimit
+	opt.AdditionalFields = []string{"DETAILED_ACCOUNTS"}
+	changes, _, err := s.gerritClient.Changes.QueryChanges(ctx, opt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	allowed := allowedChanges(ctx, *changes)
+	auditChanges(ctx, allowed)
+
+	baseURL := s.gerritClient.BaseURL()
+	list := strings.Builder{}
+	list.WriteString(fmt.Sprintf("Open changes matching %q:\n", opt.Query[0]))
+	for _, c := range allowed {
+		list.WriteString(fmt.Sprintf("- %s/c/%s/+/%d %s (owner %s, updated %s, +%d/-%d)\n",
+			strings.TrimSuffix(baseURL.String(), "/"), c.Project, c.Number, c.Subject,
+			c.Owner.Name, c.Updated.Format("2006-01-02"), c.Insertions, c.Deletions))
+	}
+	if len(allowed) == 0 {
+		list.WriteString("None.\n")
+	}
+	messages := []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(`Prioritize the Gerrit changes below for review.
+Put first the changes that are small, have waited long or look urgent from their subject, and last those that look abandoned.
+Give one line per change with its link and the reason for its position.`)),
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(s.redactPrompt(list.String()))),
+	}
+	return mcp.NewGetPromptResult(fmt.Sprintf("%s for %s", triagePromptName, project), messages), nil
+}
+
+// queryValue matches the project, branch, account and label names the triage
+// prompt puts in its query: no whitespace, quotes, parentheses, braces or
+// colons that would add search operators, and no leading dash negating one.
+var queryValue = regexp.MustCompile(`^[A-Za-z0-9_.@+~][A-Za-z0-9_.@+~/-]*$`)
+
+func checkQueryValue(name, value string) error {
+	if value != "" && !queryValue.MatchString(value) {
+		return fmt.Errorf("invalid %s %q: only letters, digits and the characters _.@+~/- are allowed", name, value)
+	}
+	return nil
+}
+
+// promptChange returns the change of a review URL after the same checks as
+// the query_change tool.
+func (s *Server) promptChange(ctx context.Context, reviewURL string) (*gerrit.ChangeInfo, error) {
+	reviewU, err := url.Parse(reviewURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse review URL: %s: %v", reviewURL, err)
+	}
+	baseURL := s.gerritClient.BaseURL()
+	if reviewU.Hostname() != baseURL.Hostname() {
+		return nil, fmt.Errorf("review URL %s is not on the Gerrit instance %s", reviewURL, baseURL.Host)
+	}
+	if err := requireScopes(ctx, ScopeChangesRead); err != nil {
+		return nil, fmt.Errorf("%w required to read changes", err)
+	}
+	found, err := change.FetchChange(ctx, s.gerritClient, reviewURL)
+	if err != nil {
+		return nil, err
+	}
+	if !projectAllowed(ctx, found.Project) {
+		return nil, fmt.Errorf("%s: token is not allowed to access project %s", ErrorCodeForbidden, found.Project)
+	}
+	auditChanges(ctx, []gerrit.ChangeInfo{*found})
+	return found, nil
+}
+
+// redactPrompt applies the secret redaction of tool results to prompts and
+// other texts sent to clients.
+func (s *Server) redactPrompt(text string) string {
+	if !s.config.RedactSecrets {
+		return text
+	}
+	return redact.String(text)
+}
@@ -0,0 +1,26 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestPromptRejectsShortReviewURL(t *testing.T) {
+	gerritClient := newTestGerritClient(t, http.NotFoundHandler(), nil)
+	s := NewServer(WithGerritClient(gerritClient))
+	mcpClient := newTestClient(t, serveTestServer(t, s), nil)
+
+	for _, changeURL := range []string{"http://127.0.0.1/q", "http://127.0.0.1/c/p/+", "http://127.0.0.1/c/chromium/src/+"} {
+		request := mcp.GetPromptRequest{}
+		request.Params.Name = "review_change"
+		request.Params.Arguments = map[string]string{"change_url": changeURL}
+		_, err := mcpClient.GetPrompt(context.Background(), request)
+		if err == nil || !strings.Contains(err.Error(), "invalid review URL") {
+			t.Errorf("review_change of %s error = %v, want an invalid review URL", changeURL, err)
+		}
+	}
+}
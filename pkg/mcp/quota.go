@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/quota"
+	"math"
+
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const getQuotaToolName = "get_quota"
+
+// QuotaMiddleware enforces per caller rate limits and the daily budget of
+// Gerrit REST calls.
+type QuotaMiddleware struct {
+	limiter *quota.Limiter
+}
+
+func NewQuotaMiddleware(limiter *quota.Limiter) *QuotaMiddleware {
+	return &QuotaMiddleware{limiter: limiter}
+}
+
+// acquire takes a slot of the caller in ctx for a request, the returned
+// context charges its Gerrit calls to the daily budget of the caller.
+func (m *QuotaMiddleware) acquire(ctx context.Context) (context.Context, func(), error) {
+	caller := quotaCallerFromContext(ctx)
+	release, err := m.limiter.Acquire(caller)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return quota.WithCaller(ctx, m.limiter, caller), release, nil
+}
+
+func (m *QuotaMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
+			// quota must stay queryable when the caller is over its limits
+			if req.Params.Name == getQuotaToolName {
+				return next(ctx, req)
+			}
+			ctx, release, err := m.acquire(ctx)
+			if err != nil {
+				var limitErr *quota.LimitError
+				if errors.As(err, &limitErr) {
+					logger.FromContext(ctx).Infof("rate limited tool %s: %v", req.Params.Name, err)
+					return newToolErrorResult(ErrorCodeRateLimited, err.Error(), map[string]any{
+						"limit":               limitErr.Limit,
+						"retry_after_seconds": int64(math.Ceil(limitErr.RetryAfter.Seconds())),
+					}), nil
+				}
+				return nil, err
+			}
+			defer release()
+			return next(ctx, req)
+		}
+	}
+}
+
+// The other methods calling Gerrit fail with a JSON-RPC error when the
+// caller is over its limits.
+
+func (m *QuotaMiddleware) ResourceMiddleware() server.ResourceHandlerMiddleware {
+	return func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+		return func(ctx context.Context, req mcpserver.ReadResourceRequest) ([]mcpserver.ResourceContents, error) {
+			ctx, release, err := m.acquire(ctx)
+			if err != nil {
+				logger.FromContext(ctx).Infof("rate limited resource %s: %v", req.Params.URI, err)
+				return nil, fmt.Errorf("%s: %w", ErrorCodeRateLimited, err)
+			}
+			defer release()
+			return next(ctx, req)
+		}
+	}
+}
+
+func (m *QuotaMiddleware) PromptMiddleware() server.PromptHandlerMiddleware {
+	return func(next server.PromptHandlerFunc) server.PromptHandlerFunc {
+		return func(ctx context.Context, req mcpserver.GetPromptRequest) (*mcpserver.GetPromptResult, error) {
+			ctx, release, err := m.acquire(ctx)
+			if err != nil {
+				logger.FromContext(ctx).Infof("rate limited prompt %s: %v", req.Params.Name, err)
+				return nil, fmt.Errorf("%s: %w", ErrorCodeRateLimited, err)
+			}
+			defer release()
+			return next(ctx, req)
+		}
+	}
+}
+
+func (m *QuotaMiddleware) CompletionMiddleware() completionMiddleware {
+	return func(next completionHandlerFunc) completionHandlerFunc {
+		return func(ctx context.Context, ref completionRef, argument mcpserver.CompleteArgument, completeContext mcpserver.CompleteContext) (*mcpserver.Completion, error) {
+			ctx, release, err := m.acquire(ctx)
+			if err != nil {
+				logger.FromContext(ctx).Debugf("rate limited completion of %s: %v", argument.Name, err)
+				return nil, fmt.Errorf("%s: %w", ErrorCodeRateLimited, err)
+			}
+			defer release()
+			return next(ctx, ref, argument, completeContext)
+		}
+	}
+}
+
+// quotaCallerFromContext identifies callers by token subject, falling back to
+// the MCP session for tokens without identity.
+func quotaCallerFromContext(ctx context.Context) string {
+	if claims := ClaimsFromContext(ctx); claims != nil && claims.Subject != "" {
+		return "key:" + claims.Subject
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return "session:" + session.SessionID()
+	}
+	return "anonymous"
+}
+
+func (s *Server) handleGetQuota(ctx context.Context, request mcpserver.CallToolRequest, args noArgs) (*mcpserver.CallToolResult, error) {
+	usage := s.quotaLimiter.Usage(quotaCallerFromContext(ctx))
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcpserver.NewToolResultStructured(usage, string(data)), nil
+}
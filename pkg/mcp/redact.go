@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"context"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/redact"
+
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RedactionMiddleware removes secrets, such as credentials leaked in diffs,
+// from the text returned by tools and resources.
+type RedactionMiddleware struct {
+	redactor *redact.Redactor
+}
+
+func NewRedactionMiddleware(redactor *redact.Redactor) *RedactionMiddleware {
+	return &RedactionMiddleware{redactor: redactor}
+}
+
+func (m *RedactionMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
+			result, err := next(ctx, req)
+			if result == nil {
+				return result, err
+			}
+			redacted := 0
+			for i, content := range result.Content {
+				if text, ok := content.(mcpserver.TextContent); ok && m.redactor.Redacted(text.Text) {
+					text.Text = m.redactor.String(text.Text)
+					result.Content[i] = text
+					redacted++
+				}
+			}
+			if redacted > 0 {
+				logger.FromContext(ctx).Infof("redacted secrets from %d tool output blocks", redacted)
+			}
+			return result, err
+		}
+	}
+}
+
+func (m *RedactionMiddleware) ResourceMiddleware() server.ResourceHandlerMiddleware {
+	return func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+		return func(ctx context.Context, req mcpserver.ReadResourceRequest) ([]mcpserver.ResourceContents, error) {
+			contents, err := next(ctx, req)
+			for i, content := range contents {
+				if text, ok := content.(mcpserver.TextResourceContents); ok && m.redactor.Redacted(text.Text) {
+					text.Text = m.redactor.String(text.Text)
+					contents[i] = text
+					logger.FromContext(ctx).Infof("redacted secrets from resource %s", text.URI)
+				}
+			}
+			return contents, err
+		}
+	}
+}
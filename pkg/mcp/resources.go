@@ -0,0 +1,249 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gerrit-mcp/internal/change"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	ChangeResourceTemplate   = "gerrit://{host}/changes/{number}"
+	FileResourceTemplate     = "gerrit://{host}/changes/{number}/revisions/{rev}/files/{+path}"
+	CommentsResourceTemplate = "gerrit://{host}/changes/{number}/comments"
+	ProjectResourceTemplate  = "gerrit://{host}/projects/{+name}"
+
+	MIMETypeText   = "text/plain"
+	MIMETypeJSON   = "application/json"
+	MIMETypeBinary = "application/octet-stream"
+
+	// Gerrit reports the type of file contents in this header
+	contentTypeHeader = "X-FYI-Content-Type"
+)
+
+// addResourceTemplates registers the Gerrit resources. Reading a resource
+// requires the same scopes as the matching tools.
+func (s *Server) addResourceTemplates() {
+	s.mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(ChangeResourceTemplate, "change",
+			mcp.WithTemplateDescription("Subject, changed files and diffs of the current revision of a change"),
+			mcp.WithTemplateMIMEType(MIMETypeText),
+		),
+		s.handleChangeResource,
+	)
+	s.mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(FileResourceTemplate, "file",
+			mcp.WithTemplateDescription("Content of a file at a revision (a patch set number, a commit SHA or \"current\") of a change"),
+		),
+		s.handleFileResource,
+	)
+	s.mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(CommentsResourceTemplate, "comments",
+			mcp.WithTemplateDescription("Published comments of a change, by file"),
+			mcp.WithTemplateMIMEType(MIMETypeJSON),
+		),
+		s.handleCommentsResource,
+	)
+	s.mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(ProjectResourceTemplate, "project",
+			mcp.WithTemplateDescription("Description, parent and state of a project"),
+			mcp.WithTemplateMIMEType(MIMETypeJSON),
+		),
+		s.handleProjectResource,
+	)
+}
+
+// resourceArgument returns an argument matched from the resource URI.
+func resourceArgument(request mcp.ReadResourceRequest, name string) string {
+	var value string
+	switch v := request.Params.Arguments[name].(type) {
+	case string:
+		value = v
+	case []string:
+		value = strings.Join(v, ",")
+	}
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// checkResourceAccess verifies that the resource belongs to the Gerrit
+// instance of the server and that the caller has the required scopes.
+func (s *Server) checkResourceAccess(ctx context.Context, request mcp.ReadResourceRequest, scopes ...string) error {
+	baseURL := s.gerritClient.BaseURL()
+	if host := resourceArgument(request, "host"); host != baseURL.Host && host != baseURL.Hostname() {
+		return fmt.Errorf("resource %s is not on the Gerrit instance %s", request.Params.URI, baseURL.Host)
+	}
+	if err := requireScopes(ctx, scopes...); err != nil {
+		return fmt.Errorf("%w required to read %s", err, request.Params.URI)
+	}
+	return nil
+}
+
+// resourceChange returns the change with the number from the resource URI,
+// provided the caller may access its project.
+func (s *Server) resourceChange(ctx context.Context, request mcp.ReadResourceRequest) (*gerrit.ChangeInfo, error) {
+	number, err := strconv.Atoi(resourceArgument(request, "number"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid change number in %s", request.Params.URI)
+	}
+	return s.changeByNumber(ctx, number)
+}
+
+// changeByNumber returns a change with its current revision, provided the
+// caller may access its project.
+func (s *Server) changeByNumber(ctx context.Context, number int) (*gerrit.ChangeInfo, error) {
+	opt := &gerrit.QueryChangeOptions{}
+	opt.Query = []string{fmt.Sprintf("change:%d", number)}
+	opt.AdditionalFields = []string{"CURRENT_REVISION"}
+	changes, _, err := s.gerritClient.Changes.QueryChanges(ctx, opt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query change %d: %w", number, err)
+	}
+	if len(*changes) == 0 {
+		return nil, fmt.Errorf("change %d not found", number)
+	}
+	found := (*changes)[0]
+	if !projectAllowed(ctx, found.Project) {
+		return nil, fmt.Errorf("%s: token is not allowed to access project %s", ErrorCodeForbidden, found.Project)
+	}
+	auditChanges(ctx, []gerrit.ChangeInfo{found})
+	return &found, nil
+}
+
+func (s *Server) handleChangeResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	if err := s.checkResourceAccess(ctx, request, ScopeChangesRead); err != nil {
+		return nil, err
+	}
+	found, err := s.resourceChange(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	gerritChanges, err := change.BuildGerritChanges(ctx, s.gerritClient, &[]gerrit.ChangeInfo{*found})
+	if err != nil {
+		return nil, err
+	}
+	text := strings.Builder{}
+	for _, gc := range gerritChanges {
+		text.WriteString(gc.TextResult())
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: request.Params.URI, MIMEType: MIMETypeText, Text: text.String()},
+	}, nil
+}
+
+func (s *Server) handleFileResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	if err := s.checkResourceAccess(ctx, request, ScopeChangesRead); err != nil {
+		return nil, err
+	}
+	found, err := s.resourceChange(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	revision := resourceArgument(request, "rev")
+	filePath := resourceArgument(request, "path")
+	// go-gerrit decodes responses as JSON while file contents are plain base64
+	req, err := s.gerritClient.NewRequest(ctx, "GET",
+		fmt.Sprintf("changes/%s/revisions/%s/files/%s/content", found.ID, url.PathEscape(revision), url.PathEscape(filePath)), nil)
+	if err != nil {
+		return nil, err
+	}
+	encoded := bytes.Buffer{}
+	resp, err := s.gerritClient.Do(req, &encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s at revision %s of change %d: %w", filePath, revision, found.Number, err)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded.String()))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected content encoding of %s: %w", filePath, err)
+	}
+
+	mimeType := fileMIMEType(filePath, resp.Header.Get(contentTypeHeader), content)
+	if isTextMIMEType(mimeType) {
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{URI: request.Params.URI, MIMEType: mimeType, Text: string(content)},
+		}, nil
+	}
+	return []mcp.ResourceContents{
+		mcp.BlobResourceContents{URI: request.Params.URI, MIMEType: mimeType, Blob: base64.StdEncoding.EncodeToString(content)},
+	}, nil
+}
+
+// fileMIMEType prefers the type reported by Gerrit and falls back to the
+// file extension, then to sniffing the content.
+func fileMIMEType(filePath, reported string, content []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(reported); err == nil && mediaType != MIMETypeBinary {
+		return mediaType
+	}
+	if byExtension := mime.TypeByExtension(path.Ext(filePath)); byExtension != "" {
+		mediaType, _, _ := mime.ParseMediaType(byExtension)
+		return mediaType
+	}
+	if utf8.Valid(content) && !bytes.ContainsRune(content, 0) {
+		return MIMETypeText
+	}
+	return MIMETypeBinary
+}
+
+func isTextMIMEType(mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case MIMETypeJSON, "application/xml", "application/javascript", "application/x-sh", "application/yaml", "image/svg+xml":
+		return true
+	}
+	return strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml")
+}
+
+func (s *Server) handleCommentsResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	if err := s.checkResourceAccess(ctx, request, ScopeChangesRead); err != nil {
+		return nil, err
+	}
+	found, err := s.resourceChange(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	comments, _, err := s.gerritClient.Changes.ListChangeComments(ctx, found.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments of change %d: %w", found.Number, err)
+	}
+	return jsonResourceContents(request.Params.URI, comments)
+}
+
+func (s *Server) handleProjectResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	if err := s.checkResourceAccess(ctx, request, ScopeProjectsRead); err != nil {
+		return nil, err
+	}
+	name := resourceArgument(request, "name")
+	if !projectAllowed(ctx, name) {
+		return nil, fmt.Errorf("%s: token is not allowed to access project %s", ErrorCodeForbidden, name)
+	}
+	project, _, err := s.gerritClient.Projects.GetProject(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project %s: %w", name, err)
+	}
+	return jsonResourceContents(request.Params.URI, project)
+}
+
+func jsonResourceContents(uri string, value any) ([]mcp.ResourceContents, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MIMEType: MIMETypeJSON, Text: string(data)},
+	}, nil
+}
@@ -2,14 +2,29 @@ package mcp
 
 import (
 	// "gerrit-mcp/internal/gerrit"
+	"cmp"
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"gerrit-mcp/internal/audit"
 	"gerrit-mcp/internal/change"
+	"gerrit-mcp/internal/events"
 	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/metrics"
 	"gerrit-mcp/internal/middlewares"
+	"gerrit-mcp/internal/quota"
+	"gerrit-mcp/internal/redact"
+	"gerrit-mcp/internal/summary"
+	"gerrit-mcp/internal/tracing"
+	"gerrit-mcp/internal/watch"
+	"maps"
+	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/andygrunwald/go-gerrit"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -27,12 +42,36 @@ const (
 	ChangeQueryDefaultStatus   = "open"
 	ChangeQueryDefaultLimit    = -1 // unlimited
 	DefaultHeaderAuthName      = "Authorization"
+	StreamableHTTPEndpointPath = "/mcp"
 )
 
 type Server struct {
-	mcpServer    *mcpserver.MCPServer
-	gerritClient *gerrit.Client
-	config       Config
+	mcpServer      *mcpserver.MCPServer
+	gerritClient   *gerrit.Client
+	tokenValidator TokenValidator
+	certValidator  *middlewares.CertificateValidator
+	tlsConfig      *tls.Config
+	auth           *AuthMiddleware
+	authz          *AuthzMiddleware
+	auditLogger    *audit.Logger
+	quotaLimiter   *quota.Limiter
+	readiness      *readinessProbe
+	completer      *completer
+	watcher        *watch.Watcher
+	eventReader    events.Reader
+	events         *events.Bus
+	recentEvents   *events.Ring
+	sampler        summary.Sampler
+	// tools holds every registered tool, sessions get the subset their
+	// caller may use
+	tools     map[string]mcpserver.ServerTool
+	lifecycle *lifecycle
+	config    Config
+	// sessions holds the ids of the registered MCP sessions
+	sessions sync.Map
+
+	mu         sync.Mutex
+	httpServer *http.Server
 }
 
 func NewServer(opts ...ServerOption) *Server {
@@ -43,95 +82,179 @@ func NewServer(opts ...ServerOption) *Server {
 	}
 	s := &Server{
 		gerritClient: client,
+		tools:        make(map[string]mcpserver.ServerTool),
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
-	authMiddleware := NewAuthMiddleware(&middlewares.SimpleTokenValidator{HeaderName: s.config.AuthHeaderName, Secret: s.config.AuthSecret})
-
-	mcpServer := mcpserver.NewMCPServer(ServerName, ServerVersion,
-		mcpserver.WithToolHandlerMiddleware(authMiddleware.ToolMiddleware()))
+	if s.tokenValidator == nil {
+		s.tokenValidator = &middlewares.SimpleTokenValidator{HeaderName: s.config.AuthHeaderName, Secret: s.config.AuthSecret}
+	}
+	s.auth = NewAuthMiddleware(s.tokenValidator, s.certValidator)
+	s.readiness = newReadinessProbe(s.gerritClient, ReadinessCacheTTL)
+	s.lifecycle = newLifecycle()
+	s.authz = NewAuthzMiddleware()
+	s.completer = newCompleter(s.gerritClient)
+	s.watcher = watch.New(s.gerritClient, s.config.WatchPollInterval, s.notifyResourceUpdated)
+	s.events = events.NewBus()
+	s.recentEvents = events.NewRing(s.config.Events.BufferSize)
+	s.events.Subscribe(s.recentEvents.Add)
+	s.events.Subscribe(s.handleGerritEvent)
+
+	hooks := metricsHooks()
+	s.addWatchHooks(hooks)
+	s.addToolsetHooks(hooks)
+
+	serverOpts := []mcpserver.ServerOption{
+		mcpserver.WithHooks(hooks),
+		// handlers parse user input, a panic must fail the request only
+		mcpserver.WithRecovery(),
+		mcpserver.WithResourceRecovery(),
+		mcpserver.WithPromptHandlerMiddleware(promptRecovery()),
+		// tools are only added to the sessions, which mcp-go does not
+		// advertise on its own
+		mcpserver.WithToolCapabilities(false),
+		mcpserver.WithResourceCapabilities(true, false),
+		mcpserver.WithLogging(),
+		mcpserver.WithCompletions(),
+		mcpserver.WithToolHandlerMiddleware(s.lifecycle.ToolMiddleware()),
+		mcpserver.WithToolHandlerMiddleware(NewLoggingMiddleware().ToolMiddleware()),
+		mcpserver.WithToolHandlerMiddleware(NewMetricsMiddleware().ToolMiddleware()),
+		mcpserver.WithToolHandlerMiddleware(NewTracingMiddleware().ToolMiddleware()),
+		mcpserver.WithToolHandlerMiddleware(s.auth.ToolMiddleware()),
+	}
+	// resources, prompts and completions call Gerrit too, they are audited
+	// and charged to the quota of the caller like tools
+	var completionMiddlewares []completionMiddleware
+	if s.auditLogger != nil {
+		auditMiddleware := NewAuditMiddleware(s.auditLogger)
+		serverOpts = append(serverOpts,
+			mcpserver.WithToolHandlerMiddleware(auditMiddleware.ToolMiddleware()),
+			mcpserver.WithResourceHandlerMiddleware(auditMiddleware.ResourceMiddleware()),
+			mcpserver.WithPromptHandlerMiddleware(auditMiddleware.PromptMiddleware()))
+		completionMiddlewares = append(completionMiddlewares, auditMiddleware.CompletionMiddleware())
+	}
+	if s.quotaLimiter != nil {
+		quotaMiddleware := NewQuotaMiddleware(s.quotaLimiter)
+		serverOpts = append(serverOpts,
+			mcpserver.WithToolHandlerMiddleware(quotaMiddleware.ToolMiddleware()),
+			mcpserver.WithResourceHandlerMiddleware(quotaMiddleware.ResourceMiddleware()),
+			mcpserver.WithPromptHandlerMiddleware(quotaMiddleware.PromptMiddleware()))
+		completionMiddlewares = append(completionMiddlewares, quotaMiddleware.CompletionMiddleware())
+	}
+	completions := newCompletionProvider(s.completer.handleCompletion, completionMiddlewares...)
+	serverOpts = append(serverOpts,
+		mcpserver.WithPromptCompletionProvider(completions),
+		mcpserver.WithResourceCompletionProvider(completions))
+	serverOpts = append(serverOpts, mcpserver.WithToolHandlerMiddleware(s.authz.ToolMiddleware()))
+	if s.config.RedactSecrets {
+		redaction := NewRedactionMiddleware(redact.Default)
+		serverOpts = append(serverOpts,
+			mcpserver.WithToolHandlerMiddleware(redaction.ToolMiddleware()),
+			mcpserver.WithResourceHandlerMiddleware(redaction.ResourceMiddleware()))
+	}
+	s.mcpServer = mcpserver.NewMCPServer(ServerName, ServerVersion, serverOpts...)
 
-	mcpServer.AddTool(
+	s.addTool(
 		mcp.NewToolWithRawSchema(
 			"query_changes_by_filter",
 			"Query changes by filter",
-			json.RawMessage(`{
-				"type": "object",
-				"properties": {
-					"status": {
-						"type": "string",
-						"description": "Status of the change"
-					},
-					"limit": {
-						"type": "number",
-						"description": "Number of changes to return"
-					},
-					"project": {
-						"type": "string",
-						"description": "Project name"
-					},
-					"age": {
-						"type": "number",
-						"description": "Age of the change in hours"
-					}
-				},
-				"required": []
-			}`),
+			toolSchema[queryChangesByFilterArgs](),
 		),
-		s.handleQueryChangesByFilter,
+		readOnlyTool("Query changes"),
+		typedTool(s.handleQueryChangesByFilter),
+		ScopeChangesRead,
 	)
 
-	mcpServer.AddTool(
+	s.addTool(
 		mcp.NewToolWithRawSchema(
 			"query_projects",
 			"Query available projects",
-			json.RawMessage(`{
-				"type": "object",
-				"properties": {
-					"prefix": {
-						"type": "string",
-						"description": "Project name prefix"
-					},
-					"limit": {
-						"type": "number",
-						"description": "Number of projects to return"
-					}
-				},
-				"required": []
-			}`),
+			toolSchema[queryProjectsArgs](),
 		),
-		s.handleQueryProjects,
+		readOnlyTool("Query projects"),
+		typedTool(s.handleQueryProjects),
+		ScopeProjectsRead,
 	)
 
-	mcpServer.AddTool(
+	s.addTool(
 		mcp.NewToolWithRawSchema(
 			"query_change",
 			"Query particular change",
-			json.RawMessage(`{
-				"type": "object",
-				"properties": {
-					"reviewURL": {
-						"type": "string",
-						"description": "Review URL"
-					},
-					"trackID": {
-						"type": "number",
-						"description": "track ID (crbug ID in case of chromium)"
-					}
-				},
-				"required": []
-			}`),
+			toolSchema[queryChangeArgs](),
+		),
+		readOnlyTool("Query change"),
+		typedTool(s.handleQueryChange),
+		ScopeChangesRead,
+	)
+
+	s.addTool(
+		mcp.NewToolWithRawSchema(
+			watchChangeToolName,
+			"Watch a change: new patch sets, comments and votes are notified to the session as updates of the change resource",
+			toolSchema[changeArgs](),
 		),
-		s.handleQueryChange,
+		readOnlyTool("Watch change"),
+		typedTool(s.handleWatchChange),
+		ScopeChangesRead,
 	)
 
-	s.mcpServer = mcpServer
+	s.addTool(
+		mcp.NewToolWithRawSchema(
+			unwatchChangeToolName,
+			"Stop watching a change",
+			toolSchema[changeArgs](),
+		),
+		readOnlyTool("Unwatch change"),
+		typedTool(s.handleUnwatchChange),
+		ScopeChangesRead,
+	)
+
+	if s.eventsEnabled() {
+		s.addTool(
+			mcp.NewToolWithRawSchema(
+				listRecentEventsToolName,
+				"List the most recent Gerrit events (new patch sets, comments, votes, merges...), newest first",
+				toolSchema[listRecentEventsArgs](),
+			),
+			localTool("List recent events"),
+			typedTool(s.handleListRecentEvents),
+			ScopeChangesRead,
+		)
+	}
+
+	if s.quotaLimiter != nil {
+		s.addTool(
+			mcp.NewToolWithRawSchema(
+				getQuotaToolName,
+				"Get the rate limits and the daily Gerrit REST call budget of the caller, and their current usage",
+				toolSchema[noArgs](),
+			),
+			localTool("Get quota"),
+			typedTool(s.handleGetQuota),
+		)
+	}
+
+	s.addResourceTemplates()
+	s.addPrompts()
+
 	return s
 }
 
+// addTool registers the tool with its annotations, along with the scopes a
+// token needs to call it. Tools are added to the sessions by
+// addToolsetHooks, depending on the Gerrit instance and the caller.
+func (s *Server) addTool(tool mcp.Tool, annotation mcp.ToolAnnotation, handler ToolHandlerFunc, scopes ...string) {
+	tool.Annotations = annotation
+	if !s.allowTool(tool) {
+		return
+	}
+	s.authz.RequireScopes(tool.Name, scopes...)
+	s.tools[tool.Name] = mcpserver.ServerTool{Tool: tool, Handler: handler}
+}
+
 type ServerOption func(*Server)
 
 func WithGerritClient(client *gerrit.Client) ServerOption {
@@ -146,43 +269,151 @@ func WithConfig(cfg Config) ServerOption {
 	}
 }
 
+func WithTokenValidator(validator TokenValidator) ServerOption {
+	return func(s *Server) {
+		s.tokenValidator = validator
+	}
+}
+
+// WithTLSConfig serves MCP over HTTPS.
+func WithTLSConfig(tlsConfig *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.tlsConfig = tlsConfig
+	}
+}
+
+// WithCertificateValidator authenticates callers presenting a verified TLS
+// client certificate and no bearer token.
+func WithCertificateValidator(validator *middlewares.CertificateValidator) ServerOption {
+	return func(s *Server) {
+		s.certValidator = validator
+	}
+}
+
+// WithAuditLogger enables audit records of tool calls. Gerrit REST calls are
+// only recorded when the Gerrit client uses an audit.Transport.
+func WithAuditLogger(auditLogger *audit.Logger) ServerOption {
+	return func(s *Server) {
+		s.auditLogger = auditLogger
+	}
+}
+
+// WithQuotaLimiter enables per caller rate limits. The daily Gerrit REST call
+// budget is only charged when the Gerrit client uses a quota.Transport.
+func WithQuotaLimiter(limiter *quota.Limiter) ServerOption {
+	return func(s *Server) {
+		s.quotaLimiter = limiter
+	}
+}
+
 func (s *Server) Serve(addr string) error {
+	mux := http.NewServeMux()
+	if s.config.MetricsPath != "" {
+		mux.Handle(s.config.MetricsPath, metrics.Handler())
+	}
+	mux.HandleFunc(HealthzPath, s.handleHealthz)
+	mux.HandleFunc(ReadyzPath, s.handleReadyz)
+	mux.Handle(InfoPath, requestIDMiddleware(s.auth.HTTPMiddleware(http.HandlerFunc(s.handleInfo))))
+	if webhook := s.config.Events.Webhook; webhook.Enabled() {
+		path := webhook.Path
+		if path == "" {
+			path = events.DefaultWebhookPath
+		}
+		// deliveries are authenticated with the webhook secret, not as MCP clients
+		mux.Handle(path, requestIDMiddleware(events.NewWebhookHandler(webhook.Secret, s.events)))
+	}
+	if !s.tokenValidator.IsDisabled() || s.certValidator != nil {
+		mux.Handle(LogLevelPath, requestIDMiddleware(s.auth.HTTPMiddleware(http.HandlerFunc(s.handleLogLevel))))
+	} else {
+		logger.Infof("Authentication disabled, not serving %s", LogLevelPath)
+	}
 	if s.config.UseSSE {
-		return s.serveSSE(addr)
+		s.serveSSE(mux, addr)
+	} else {
+		s.serverStreamableHTTP(mux, addr)
+	}
+	httpServer := &http.Server{
+		Addr:      addr,
+		Handler:   mux,
+		TLSConfig: s.tlsConfig,
+	}
+	s.mu.Lock()
+	s.httpServer = httpServer
+	s.mu.Unlock()
+	if s.eventReader != nil {
+		go s.runEvents(s.lifecycle.streamsCtx)
+	} else {
+		go s.watcher.Run(s.lifecycle.streamsCtx)
+	}
+	var err error
+	if s.tlsConfig != nil {
+		// the certificates come from the TLS configuration
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
 	}
-	return s.serverStreamableHTTP(addr)
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
-func (s *Server) serveSSE(addr string) error {
+func (s *Server) serveSSE(mux *http.ServeMux, addr string) {
 	logger.Debugf("Starting MCP server (SSE) on %s", addr)
 	sseServer := server.NewSSEServer(s.mcpServer)
-	return sseServer.Start(addr)
+	sessionID := func(r *http.Request) string { return r.URL.Query().Get("sessionId") }
+	// responses are sent on the event stream of the session
+	reject := func(w http.ResponseWriter, sessionID string, response mcp.JSONRPCError) {
+		if err := sseServer.SendEventToSession(sessionID, response); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+	mux.Handle(sseServer.CompleteSsePath(), s.httpMiddleware(sseServer.SSEHandler()))
+	mux.Handle(sseServer.CompleteMessagePath(), s.httpMiddleware(s.subscriptionMiddleware(sseServer.MessageHandler(), sessionID, reject)))
 }
 
-func (s *Server) serverStreamableHTTP(addr string) error {
+func (s *Server) serverStreamableHTTP(mux *http.ServeMux, addr string) {
 	logger.Debugf("Starting MCP server (Streamable HTTP Server) on %s", addr)
-	return server.NewStreamableHTTPServer(s.mcpServer).Start(addr)
+	mux.Handle(StreamableHTTPEndpointPath, s.streamableHTTPHandler())
+}
+
+// streamableHTTPHandler serves the MCP endpoint over streamable HTTP.
+func (s *Server) streamableHTTPHandler() http.Handler {
+	sessionID := func(r *http.Request) string { return r.Header.Get(server.HeaderKeySessionID) }
+	return s.httpMiddleware(s.subscriptionMiddleware(server.NewStreamableHTTPServer(s.mcpServer,
+		server.WithEndpointPath(StreamableHTTPEndpointPath)), sessionID, writeJSONRPCError))
+}
+
+// httpMiddleware wraps the MCP transport handlers.
+func (s *Server) httpMiddleware(next http.Handler) http.Handler {
+	return metrics.InstrumentHandler(tracing.ExtractHandler(requestIDMiddleware(s.lifecycle.HTTPMiddleware(s.auth.HTTPMiddleware(next)))))
 }
 
-func (s *Server) handleQueryChangesByFilter(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	status := mcp.ParseString(request, "status", ChangeQueryDefaultStatus)
-	limit := mcp.ParseInt(request, "limit", ChangeQueryDefaultLimit)
-	project := mcp.ParseString(request, "project", ChangeQueryDefaultProject)
-	age := mcp.ParseInt(request, "age", ChangeQueryDefaultAgeHours)
+func (s *Server) handleQueryChangesByFilter(ctx context.Context, request mcp.CallToolRequest, args queryChangesByFilterArgs) (*mcp.CallToolResult, error) {
+	status := cmp.Or(args.Status, ChangeQueryDefaultStatus)
+	limit := cmp.Or(args.Limit, ChangeQueryDefaultLimit)
+	project := cmp.Or(args.Project, ChangeQueryDefaultProject)
+	age := cmp.Or(args.Age, ChangeQueryDefaultAgeHours)
 
 	opt := &gerrit.QueryChangeOptions{}
+	// the current revision SHA makes files and diffs cacheable
+	opt.AdditionalFields = []string{"CURRENT_REVISION"}
 	queryParts := []string{
 		"status:" + status,
 		// "project:" + project,
 	}
-	if project == ChangeQueryDefaultProject {
-		queryParts = append(queryParts, "project:"+project)
-	} else {
-		queryParts = append(queryParts, "project:"+change.GetCorrectProjectName(ctx, s.gerritClient, project, ChangeQueryDefaultProject))
+	if project != ChangeQueryDefaultProject {
+		project = change.GetCorrectProjectName(ctx, s.gerritClient, project, ChangeQueryDefaultProject)
+	}
+	if !projectAllowed(ctx, project) {
+		return projectForbiddenResult(project), nil
 	}
+	queryParts = append(queryParts, "project:"+project)
 
 	if age != ChangeQueryDefaultAgeHours {
-		queryParts = append(queryParts, fmt.Sprintf("age:%dh", age))
+		queryParts = append(queryParts, "age:"+formatAge(age))
 	}
 	opt.Query = []string{strings.Join(queryParts, " ")}
 	opt.Limit = limit
@@ -194,36 +425,39 @@ func (s *Server) handleQueryChangesByFilter(ctx context.Context, request mcp.Cal
 	if len(*changes) == 0 {
 		return nil, fmt.Errorf("no change found for query %s", opt.Query[0])
 	}
+	allowed := allowedChanges(ctx, *changes)
+	if len(allowed) == 0 {
+		return projectForbiddenResult((*changes)[0].Project), nil
+	}
+	changes = &allowed
+	auditChanges(ctx, *changes)
 
 	gerritChanges, err := change.BuildGerritChanges(ctx, s.gerritClient, changes)
 	if err != nil {
 		return nil, err
 	}
 
-	logger.Debugf("extracted %d changes", len(gerritChanges))
+	logger.FromContext(ctx).Debugf("extracted %d changes", len(gerritChanges))
 
 	if limit != ChangeQueryDefaultLimit && len(gerritChanges) > limit {
 		gerritChanges = gerritChanges[:limit]
 	}
 
-	resultBuilder := strings.Builder{}
-	for _, gc := range gerritChanges {
-		resultBuilder.WriteString(gc.TextResult())
-	}
-	return mcp.NewToolResultText(resultBuilder.String()), nil
+	return mcp.NewToolResultText(s.changesText(ctx, gerritChanges, args.Summarize)), nil
 }
 
-func (s *Server) handleQueryChange(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	reviewURL := mcp.ParseString(request, "reviewURL", "")
-	trackID := mcp.ParseInt(request, "trackID", -1)
+func (s *Server) handleQueryChange(ctx context.Context, request mcp.CallToolRequest, args queryChangeArgs) (*mcp.CallToolResult, error) {
+	reviewURL := args.ReviewURL
+	trackID := args.TrackID
 
-	if reviewURL == "" && trackID == -1 {
+	if reviewURL == "" && trackID == 0 {
 		return nil, fmt.Errorf("either reviewURL or trackID must be provided")
 	}
 
 	opt := &gerrit.QueryChangeOptions{}
+	opt.AdditionalFields = []string{"CURRENT_REVISION"}
 	if reviewURL != "" {
-		query, err := change.BuildQueryFromURL(reviewURL)
+		query, err := change.BuildQueryFromURL(ctx, reviewURL)
 		reviewU, _ := url.Parse(reviewURL)
 		if err != nil {
 			return nil, fmt.Errorf("unable to parse review URL: %s: %v", reviewURL, err)
@@ -231,7 +465,7 @@ func (s *Server) handleQueryChange(ctx context.Context, request mcp.CallToolRequ
 		u := s.gerritClient.BaseURL()
 		clientHost := u.Hostname()
 		if clientHost != reviewU.Hostname() {
-			logger.Errorf("host %s from review URL %s is not the same as the host %s from the server", reviewU.Hostname(), reviewURL, clientHost)
+			logger.FromContext(ctx).Errorf("host %s from review URL %s is not the same as the host %s from the server", reviewU.Hostname(), reviewURL, clientHost)
 			return nil, fmt.Errorf("review URL is not from the same gerrit instance as the one used to create the server")
 		}
 		if err != nil {
@@ -249,24 +483,26 @@ func (s *Server) handleQueryChange(ctx context.Context, request mcp.CallToolRequ
 	if len(*changes) == 0 {
 		return nil, fmt.Errorf("no change found for trackID %d", trackID)
 	}
+	allowed := allowedChanges(ctx, *changes)
+	if len(allowed) == 0 {
+		return projectForbiddenResult((*changes)[0].Project), nil
+	}
+	changes = &allowed
+	auditChanges(ctx, *changes)
 
 	gerritChanges, err := change.BuildGerritChanges(ctx, s.gerritClient, changes)
 	if err != nil {
 		return nil, err
 	}
 
-	logger.Debugf("extracted %d changes", len(gerritChanges))
+	logger.FromContext(ctx).Debugf("extracted %d changes", len(gerritChanges))
 
-	resultBuilder := strings.Builder{}
-	for _, gc := range gerritChanges {
-		resultBuilder.WriteString(gc.TextResult())
-	}
-	return mcp.NewToolResultText(resultBuilder.String()), nil
+	return mcp.NewToolResultText(s.changesText(ctx, gerritChanges, args.Summarize)), nil
 }
 
-func (s *Server) handleQueryProjects(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	prefix := mcp.ParseString(request, "prefix", "")
-	limit := mcp.ParseInt(request, "limit", ChangeQueryDefaultLimit)
+func (s *Server) handleQueryProjects(ctx context.Context, request mcp.CallToolRequest, args queryProjectsArgs) (*mcp.CallToolResult, error) {
+	prefix := args.Prefix
+	limit := cmp.Or(args.Limit, ChangeQueryDefaultLimit)
 	opt := &gerrit.ProjectOptions{
 		ProjectBaseOptions: gerrit.ProjectBaseOptions{
 			Limit: limit,
@@ -276,15 +512,41 @@ func (s *Server) handleQueryProjects(ctx context.Context, request mcp.CallToolRe
 	if prefix != "" {
 		opt.Prefix = prefix
 	}
-	projects, _, err := s.gerritClient.Projects.ListProjects(ctx, opt)
-	if err != nil {
-		return nil, err
-	}
 	// TODO: better response representation
 	resultBuilder := strings.Builder{}
-	for name, project := range *projects {
-		logger.Debugf("Found project: %s", name)
-		resultBuilder.WriteString(fmt.Sprintf("%s: %s\n", name, project.Description))
+	found, skip := 0, 0
+	// Gerrit applies the limit before the projects the token may not access
+	// are dropped, so pages are fetched until limit projects are allowed or
+	// the list ends
+	for {
+		projects, _, err := s.gerritClient.Projects.ListProjects(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range slices.Sorted(maps.Keys(*projects)) {
+			if limit > 0 && found == limit {
+				break
+			}
+			if !projectAllowed(ctx, name) {
+				continue
+			}
+			logger.FromContext(ctx).Debugf("Found project: %s", name)
+			resultBuilder.WriteString(fmt.Sprintf("%s: %s\n", name, (*projects)[name].Description))
+			found++
+		}
+		if limit <= 0 || found == limit || len(*projects) < limit {
+			break
+		}
+		skip += len(*projects)
+		opt.Skip = strconv.Itoa(skip)
 	}
 	return mcp.NewToolResultText(resultBuilder.String()), nil
 }
+
+func auditChanges(ctx context.Context, changes []gerrit.ChangeInfo) {
+	numbers := make([]int, 0, len(changes))
+	for _, c := range changes {
+		numbers = append(numbers, c.Number)
+	}
+	audit.AddChanges(ctx, numbers...)
+}
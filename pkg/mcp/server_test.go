@@ -0,0 +1,150 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"gerrit-mcp/internal/middlewares"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// writeGerritJSON answers like the Gerrit REST API, with the XSSI prefix.
+func writeGerritJSON(t *testing.T, w http.ResponseWriter, value any) {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(")]}'\n"))
+	w.Write(data)
+}
+
+// newTestGerritClient returns a client of a fake Gerrit served by handler.
+func newTestGerritClient(t *testing.T, handler http.Handler, httpClient *http.Client) *gerrit.Client {
+	t.Helper()
+	fake := httptest.NewServer(handler)
+	t.Cleanup(fake.Close)
+	gerritClient, err := gerrit.NewClient(context.Background(), fake.URL, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gerritClient
+}
+
+// serveTestServer serves s over streamable HTTP with the middlewares of
+// Serve, and returns the URL of its MCP endpoint.
+func serveTestServer(t *testing.T, s *Server) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(StreamableHTTPEndpointPath, s.streamableHTTPHandler())
+	httpServer := httptest.NewServer(mux)
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL + StreamableHTTPEndpointPath
+}
+
+// newTestClient connects an initialized MCP client to url.
+func newTestClient(t *testing.T, url string, headers map[string]string, opts ...client.ClientOption) *client.Client {
+	t.Helper()
+	httpTransport, err := transport.NewStreamableHTTP(url, transport.WithHTTPHeaders(headers), transport.WithContinuousListening())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcpClient := client.NewClient(httpTransport, opts...)
+	t.Cleanup(func() { mcpClient.Close() })
+	ctx := context.Background()
+	if err := mcpClient.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "test", Version: "1.0.0"}
+	if _, err := mcpClient.Initialize(ctx, initRequest); err != nil {
+		t.Fatal(err)
+	}
+	return mcpClient
+}
+
+func callTool(t *testing.T, mcpClient *client.Client, name string, arguments map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = arguments
+	result, err := mcpClient.CallTool(context.Background(), request)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return result
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			return text.Text
+		}
+	}
+	return ""
+}
+
+// claimsValidator accepts any bearer token as the given claims.
+type claimsValidator struct {
+	claims *middlewares.Claims
+}
+
+func (v claimsValidator) Extract(ctx context.Context, header http.Header) string {
+	return strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+}
+
+func (v claimsValidator) Validate(token string) (any, error) {
+	return v.claims, nil
+}
+
+func (v claimsValidator) IsDisabled() bool {
+	return false
+}
+
+func TestQueryProjectsPagesThroughAllowList(t *testing.T) {
+	names := []string{"chromium/src", "infra/a", "infra/b", "v8/v8", "infra/c", "skia"}
+	var skips []string
+	gerritClient := newTestGerritClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/" {
+			http.NotFound(w, r)
+			return
+		}
+		skips = append(skips, r.URL.Query().Get("S"))
+		skip, _ := strconv.Atoi(r.URL.Query().Get("S"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		projects := make(map[string]gerrit.ProjectInfo)
+		for _, name := range names[min(skip, len(names)):min(skip+limit, len(names))] {
+			projects[name] = gerrit.ProjectInfo{Description: name + " project"}
+		}
+		writeGerritJSON(t, w, projects)
+	}), nil)
+	s := NewServer(WithGerritClient(gerritClient), WithTokenValidator(claimsValidator{&middlewares.Claims{
+		Scopes:   []string{ScopeProjectsRead},
+		Projects: []string{"infra/*"},
+	}}))
+	mcpClient := newTestClient(t, serveTestServer(t, s), map[string]string{"Authorization": "Bearer token"})
+
+	result := callTool(t, mcpClient, "query_projects", map[string]any{"limit": 2})
+	if want := "infra/a: infra/a project\ninfra/b: infra/b project\n"; resultText(result) != want {
+		t.Errorf("query_projects limit 2 = %q, want %q", resultText(result), want)
+	}
+	skips = nil
+	result = callTool(t, mcpClient, "query_projects", map[string]any{"limit": 4})
+	if want := "infra/a: infra/a project\ninfra/b: infra/b project\ninfra/c: infra/c project\n"; resultText(result) != want {
+		t.Errorf("query_projects limit 4 = %q, want %q", resultText(result), want)
+	}
+	if want := []string{"", "4"}; !slices.Equal(skips, want) {
+		t.Errorf("skipped projects of the pages = %v, want %v", skips, want)
+	}
+}
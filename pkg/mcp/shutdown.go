@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"gerrit-mcp/internal/logger"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const DefaultShutdownGracePeriod = 30 * time.Second
+
+// ErrToolCallsCancelled is returned by Shutdown when tool calls were still
+// running at the end of the grace period and had to be cancelled.
+var ErrToolCallsCancelled = errors.New("in-flight tool calls cancelled at the end of the shutdown grace period")
+
+// lifecycle tracks in-flight tool calls and open MCP connections so that
+// Shutdown can drain them.
+type lifecycle struct {
+	draining atomic.Bool
+	// callsCtx is the parent of every tool call, it is cancelled once the
+	// grace period has elapsed so that pending Gerrit requests are aborted
+	callsCtx    context.Context
+	cancelCalls context.CancelFunc
+	// streamsCtx is cancelled to close the SSE and streamable HTTP streams
+	// once tool calls are done
+	streamsCtx    context.Context
+	cancelStreams context.CancelFunc
+
+	mu    sync.Mutex
+	calls int
+	idle  chan struct{}
+}
+
+func newLifecycle() *lifecycle {
+	l := &lifecycle{}
+	l.callsCtx, l.cancelCalls = context.WithCancel(context.Background())
+	l.streamsCtx, l.cancelStreams = context.WithCancel(context.Background())
+	return l
+}
+
+func (l *lifecycle) callStarted() {
+	l.mu.Lock()
+	l.calls++
+	l.mu.Unlock()
+}
+
+func (l *lifecycle) callDone() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls--
+	if l.calls == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+}
+
+// idleChan returns a channel closed once no tool call is running.
+func (l *lifecycle) idleChan() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	idle := make(chan struct{})
+	if l.calls == 0 {
+		close(idle)
+	} else {
+		l.idle = idle
+	}
+	return idle
+}
+
+// ToolMiddleware counts in-flight tool calls and cancels them when the
+// grace period of a shutdown has elapsed.
+func (l *lifecycle) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
+			l.callStarted()
+			defer l.callDone()
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			stop := context.AfterFunc(l.callsCtx, cancel)
+			defer stop()
+			return next(ctx, req)
+		}
+	}
+}
+
+// HTTPMiddleware refuses new sessions while the server is shutting down,
+// requests of established sessions are still served. Long-lived GET streams
+// are ended when streamsCtx is cancelled.
+func (l *lifecycle) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.draining.Load() && isNewSession(r) {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodGet {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			stop := context.AfterFunc(l.streamsCtx, cancel)
+			defer stop()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isNewSession reports whether r opens an MCP session: an SSE stream, or a
+// streamable HTTP request without a session id.
+func isNewSession(r *http.Request) bool {
+	if r.Header.Get(server.HeaderKeySessionID) != "" {
+		return false
+	}
+	// SSE clients post messages with the session id in the query
+	return r.URL.Query().Get("sessionId") == ""
+}
+
+// Shutdown stops accepting new sessions and waits for the in-flight tool
+// calls. Calls still running after the configured grace period are
+// cancelled, which aborts their Gerrit requests, and ErrToolCallsCancelled
+// is returned. Open SSE and streamable HTTP streams are closed once the tool
+// calls are done. ctx bounds the whole shutdown.
+func (s *Server) Shutdown(ctx context.Context) error {
+	l := s.lifecycle
+	l.draining.Store(true)
+
+	s.mu.Lock()
+	httpServer := s.httpServer
+	s.mu.Unlock()
+	httpDone := make(chan error, 1)
+	if httpServer != nil {
+		go func() {
+			httpDone <- httpServer.Shutdown(ctx)
+		}()
+	} else {
+		httpDone <- nil
+	}
+
+	grace := s.config.ShutdownGracePeriod
+	if grace <= 0 {
+		grace = DefaultShutdownGracePeriod
+	}
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-l.idleChan():
+	case <-timer.C:
+		logger.Errorf("Shutdown grace period of %v elapsed, cancelling in-flight tool calls", grace)
+		err = ErrToolCallsCancelled
+		l.cancelCalls()
+		select {
+		case <-l.idleChan():
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+		l.cancelCalls()
+		err = ErrToolCallsCancelled
+	}
+	l.cancelCalls()
+	// tool results of SSE sessions are delivered on the stream, close the
+	// streams only once the calls are done
+	l.cancelStreams()
+
+	if httpErr := <-httpDone; httpErr != nil {
+		httpServer.Close()
+		return errors.Join(err, httpErr)
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"gerrit-mcp/internal/change"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/summary"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// summariesUnavailable is prepended to the raw changes when summaries
+	// were asked for but the client cannot sample.
+	summariesUnavailable = "Summaries are unavailable: the MCP client does not support sampling. Returning the diffs instead.\n\n"
+	// samplingTimeout bounds each sampling request, clients may ask their
+	// user to approve them, or never receive them without an open stream
+	samplingTimeout = 2 * time.Minute
+	// maxSummarizedChanges bounds the changes summarized by a call, each
+	// costing a sampling request per file
+	maxSummarizedChanges = 10
+)
+
+// WithSampler summarizes changes with sampler instead of the model of the
+// calling client, e.g. a stub.
+func WithSampler(sampler summary.Sampler) ServerOption {
+	return func(s *Server) {
+		s.sampler = sampler
+	}
+}
+
+// sessionSampler samples the model of the client of the session in ctx with
+// sampling/createMessage requests.
+type sessionSampler struct {
+	server *Server
+}
+
+func (s sessionSampler) Sample(ctx context.Context, request summary.Request) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, samplingTimeout)
+	defer cancel()
+	result, err := s.server.mcpServer.RequestSampling(ctx, mcp.CreateMessageRequest{
+		CreateMessageParams: mcp.CreateMessageParams{
+			Messages: []mcp.SamplingMessage{{
+				Role:    mcp.RoleUser,
+				Content: mcp.NewTextContent(s.server.redactPrompt(request.Prompt)),
+			}},
+			SystemPrompt:   request.SystemPrompt,
+			IncludeContext: "none",
+			MaxTokens:      request.MaxTokens,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	switch content := result.Content.(type) {
+	case mcp.TextContent:
+		return content.Text, nil
+	case *mcp.TextContent:
+		return content.Text, nil
+	}
+	return "", fmt.Errorf("the model answered with %T content instead of text", result.Content)
+}
+
+// samplerFromContext returns the sampler summarizing changes for the caller,
+// nil when its client did not declare the sampling capability.
+func (s *Server) samplerFromContext(ctx context.Context) summary.Sampler {
+	if s.sampler != nil {
+		return s.sampler
+	}
+	session := server.ClientSessionFromContext(ctx)
+	if _, ok := session.(server.SessionWithSampling); !ok {
+		return nil
+	}
+	withInfo, ok := session.(server.SessionWithClientInfo)
+	if !ok || withInfo.GetClientCapabilities().Sampling == nil {
+		return nil
+	}
+	return sessionSampler{server: s}
+}
+
+// changesText renders changes with their diffs or, when summarize is set,
+// with the summaries of the model of the client for the first
+// maxSummarizedChanges. Once a summary fails, the remaining changes keep
+// their diffs rather than failing the same way.
+func (s *Server) changesText(ctx context.Context, gerritChanges []change.GerritChange, summarize bool) string {
+	resultBuilder := strings.Builder{}
+	var sampler summary.Sampler
+	if summarize {
+		if sampler = s.samplerFromContext(ctx); sampler == nil {
+			resultBuilder.WriteString(summariesUnavailable)
+		}
+	}
+	for i, gc := range gerritChanges {
+		if sampler != nil && i == maxSummarizedChanges {
+			resultBuilder.WriteString(fmt.Sprintf("Only the first %d changes are summarized. Returning the diffs of the others.\n\n", maxSummarizedChanges))
+			sampler = nil
+		}
+		if sampler == nil {
+			resultBuilder.WriteString(gc.TextResult())
+			continue
+		}
+		changeSummary, err := summary.Change(ctx, sampler, &gc)
+		if err != nil {
+			logger.FromContext(ctx).Warnf("Returning diffs instead of summaries from %s: %v", gc.URL, err)
+			resultBuilder.WriteString(fmt.Sprintf("Summaries are unavailable (%v). Returning the diffs instead.\n\n", err))
+			resultBuilder.WriteString(gc.TextResult())
+			sampler = nil
+			continue
+		}
+		resultBuilder.WriteString(changeSummary.Text())
+	}
+	return resultBuilder.String()
+}
@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gerrit-mcp/internal/change"
+	"gerrit-mcp/internal/summary"
+)
+
+func testChanges(count int) []change.GerritChange {
+	changes := make([]change.GerritChange, 0, count)
+	for i := range count {
+		path := fmt.Sprintf("src/file%d.cc", i)
+		changes = append(changes, change.GerritChange{
+			Subject: fmt.Sprintf("Change %d", i),
+			Project: "v8/v8",
+			URL:     fmt.Sprintf("https://gerrit.example.com/c/v8/v8/+/%d", i),
+			Paths:   []string{path},
+			DiffMap: map[string]string{path: fmt.Sprintf("+diff of change %d", i)},
+		})
+	}
+	return changes
+}
+
+func TestChangesText(t *testing.T) {
+	sampled := 0
+	stub := summary.SamplerFunc(func(ctx context.Context, request summary.Request) (string, error) {
+		sampled++
+		return "stub summary", nil
+	})
+	failing := summary.SamplerFunc(func(ctx context.Context, request summary.Request) (string, error) {
+		// the files and the change of the first change succeed
+		if sampled++; sampled > 2 {
+			return "", errors.New("the user declined")
+		}
+		return "stub summary", nil
+	})
+
+	tests := []struct {
+		name        string
+		sampler     summary.Sampler
+		changes     int
+		summarize   bool
+		wantSampled int
+		// wantSummaries is the number of leading changes summarized, the
+		// others are returned with their diffs
+		wantSummaries int
+		wantNote      string
+	}{
+		{name: "diffs", sampler: stub, changes: 2, wantSampled: 0, wantSummaries: 0},
+		{name: "summaries", sampler: stub, changes: 2, summarize: true, wantSampled: 4, wantSummaries: 2},
+		{name: "no sampling", changes: 2, summarize: true, wantSummaries: 0, wantNote: summariesUnavailable},
+		{
+			name: "failure midway", sampler: failing, changes: 3, summarize: true, wantSampled: 3, wantSummaries: 1,
+			wantNote: "Summaries are unavailable (failed to summarize src/file1.cc: the user declined). Returning the diffs instead.",
+		},
+		{
+			name: "beyond the changes cap", sampler: stub, changes: maxSummarizedChanges + 2, summarize: true,
+			wantSampled: 2 * maxSummarizedChanges, wantSummaries: maxSummarizedChanges,
+			wantNote: fmt.Sprintf("Only the first %d changes are summarized.", maxSummarizedChanges),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampled = 0
+			var opts []ServerOption
+			if tt.sampler != nil {
+				opts = append(opts, WithSampler(tt.sampler))
+			}
+			s := NewServer(opts...)
+			// without a session, only WithSampler can summarize
+			text := s.changesText(context.Background(), testChanges(tt.changes), tt.summarize)
+
+			if sampled != tt.wantSampled {
+				t.Errorf("sampled %d times, want %d", sampled, tt.wantSampled)
+			}
+			if tt.wantNote != "" && !strings.Contains(text, tt.wantNote) {
+				t.Errorf("text lacks the note %q:\n%s", tt.wantNote, text)
+			}
+			for i := range tt.changes {
+				summarized := strings.Contains(text, fmt.Sprintf("src/file%d.cc: stub summary", i))
+				diffed := strings.Contains(text, fmt.Sprintf("+diff of change %d\n", i))
+				if want := i < tt.wantSummaries; summarized != want || diffed == want {
+					t.Errorf("change %d summarized = %t, with its diff = %t, want it summarized = %t:\n%s", i, summarized, diffed, want, text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"gerrit-mcp/internal/middlewares"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ToolsConfig enables and disables tools by name or path.Match pattern, e.g.
+// "query_*".
+type ToolsConfig struct {
+	// Enabled limits the tools to the listed ones, every tool when empty.
+	Enabled []string `yaml:"Enabled"`
+	// Disabled removes the listed tools, even when they match Enabled.
+	Disabled []string `yaml:"Disabled"`
+}
+
+func (c ToolsConfig) Allows(tool string) bool {
+	if middlewares.MatchAny(c.Disabled, tool) {
+		return false
+	}
+	return len(c.Enabled) == 0 || middlewares.MatchAny(c.Enabled, tool)
+}
+
+func (c ToolsConfig) Validate() error {
+	for _, patterns := range [][]string{c.Enabled, c.Disabled} {
+		if err := middlewares.ValidatePatterns(patterns); err != nil {
+			return fmt.Errorf("invalid tools configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// toolNames returns the names of the tools offered on the Gerrit instance,
+// before the restrictions of the callers.
+func (s *Server) toolNames() []string {
+	tools := s.instanceConfig().Tools
+	names := make([]string, 0, len(s.tools))
+	for name := range s.tools {
+		if tools.Allows(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// effectiveTools returns the tools offered to the caller authenticated in
+// ctx: the tools of the Gerrit instance also allowed by the token.
+func (s *Server) effectiveTools(ctx context.Context) []server.ServerTool {
+	claims := ClaimsFromContext(ctx)
+	tools := make([]server.ServerTool, 0, len(s.tools))
+	for _, name := range s.toolNames() {
+		if claims == nil || claims.AllowsTool(name) {
+			tools = append(tools, s.tools[name])
+		}
+	}
+	return tools
+}
+
+func (s *Server) instanceConfig() InstanceConfig {
+	baseURL := s.gerritClient.BaseURL()
+	return s.config.Instance(baseURL.String())
+}
+
+// addToolsetHooks gives each session its effective tools when it is
+// registered, so that tools/list only returns the tools the caller may call
+// and the other tools are unknown to the session.
+func (s *Server) addToolsetHooks(hooks *server.Hooks) {
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		withTools, ok := session.(server.SessionWithTools)
+		if !ok {
+			logger.FromContext(ctx).Errorf("Session %s does not support tools", session.SessionID())
+			return
+		}
+		tools := s.effectiveTools(ctx)
+		sessionTools := make(map[string]server.ServerTool, len(tools))
+		for _, tool := range tools {
+			sessionTools[tool.Tool.Name] = tool
+		}
+		withTools.SetSessionTools(sessionTools)
+		logger.FromContext(ctx).Debugf("Session %s offers %d tools", session.SessionID(), len(sessionTools))
+	})
+}
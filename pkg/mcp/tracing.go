@@ -0,0 +1,52 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"gerrit-mcp/internal/audit"
+	"gerrit-mcp/internal/tracing"
+
+	mcpserver "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware starts a span per tool call, Gerrit REST requests made
+// by the tool become its children.
+type TracingMiddleware struct{}
+
+func NewTracingMiddleware() *TracingMiddleware {
+	return &TracingMiddleware{}
+}
+
+func (m *TracingMiddleware) ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpserver.CallToolRequest) (*mcpserver.CallToolResult, error) {
+			attrs := []attribute.KeyValue{
+				attribute.String("mcp.tool.name", req.Params.Name),
+				attribute.String("mcp.caller", callerFromContext(ctx)),
+			}
+			if arguments, err := json.Marshal(audit.RedactArguments(req.GetArguments())); err == nil {
+				attrs = append(attrs, attribute.String("mcp.tool.arguments", string(arguments)))
+			}
+			if session := server.ClientSessionFromContext(ctx); session != nil {
+				attrs = append(attrs, attribute.String("mcp.session.id", session.SessionID()))
+			}
+			ctx, span := tracing.Tracer().Start(ctx, "tools/call "+req.Params.Name,
+				trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			result, err := next(ctx, req)
+			switch {
+			case err != nil:
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			case result != nil && result.IsError:
+				span.SetStatus(codes.Error, resultErrorMessage(result))
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gerrit-mcp/internal/tracing"
+
+	"github.com/andygrunwald/go-gerrit"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, kind trace.SpanKind, namePrefix string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.SpanKind == kind && strings.HasPrefix(span.Name, namePrefix) {
+			return span
+		}
+	}
+	t.Fatalf("no %s span %q in %d spans", kind, namePrefix, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func TestTracingToolCall(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewTracerProvider(exporter, "test", sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	gerritTraceparent := make(chan string, 1)
+	gerritClient := newTestGerritClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/" {
+			http.NotFound(w, r)
+			return
+		}
+		select {
+		case gerritTraceparent <- r.Header.Get("traceparent"):
+		default:
+		}
+		writeGerritJSON(t, w, map[string]gerrit.ProjectInfo{"chromium/src": {Description: "Chromium"}})
+	}), &http.Client{Transport: tracing.NewTransport(nil)})
+
+	s := NewServer(WithGerritClient(gerritClient))
+	mcpClient := newTestClient(t, serveTestServer(t, s), map[string]string{
+		"traceparent": "00-" + testTraceID + "-" + testSpanID + "-01",
+	})
+	result := callTool(t, mcpClient, "query_projects", map[string]any{"prefix": "chromium"})
+	if result.IsError || !strings.Contains(resultText(result), "chromium/src") {
+		t.Fatalf("query_projects = %+v", result)
+	}
+
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+
+	toolSpan := findSpan(t, spans, trace.SpanKindServer, "tools/call query_projects")
+	if got := toolSpan.SpanContext.TraceID().String(); got != testTraceID {
+		t.Errorf("tool span trace ID = %s, want the one of the traceparent %s", got, testTraceID)
+	}
+	if !toolSpan.Parent.IsRemote() || toolSpan.Parent.SpanID().String() != testSpanID {
+		t.Errorf("tool span parent = %v, want the remote span %s", toolSpan.Parent.SpanID(), testSpanID)
+	}
+	if got := spanAttribute(toolSpan, "mcp.tool.name").AsString(); got != "query_projects" {
+		t.Errorf("mcp.tool.name = %q", got)
+	}
+	if got := spanAttribute(toolSpan, "mcp.tool.arguments").AsString(); got != `{"prefix":"chromium"}` {
+		t.Errorf("mcp.tool.arguments = %q", got)
+	}
+
+	gerritSpan := findSpan(t, spans, trace.SpanKindClient, "GET /projects/")
+	if gerritSpan.Parent.SpanID() != toolSpan.SpanContext.SpanID() {
+		t.Errorf("Gerrit span parent = %v, want the tool span %v", gerritSpan.Parent.SpanID(), toolSpan.SpanContext.SpanID())
+	}
+	for key, want := range map[attribute.Key]string{
+		"http.request.method": "GET",
+		"gerrit.endpoint":     "/projects/",
+		"server.address":      "127.0.0.1",
+	} {
+		if got := spanAttribute(gerritSpan, key).AsString(); got != want {
+			t.Errorf("Gerrit span %s = %q, want %q", key, got, want)
+		}
+	}
+	if got := spanAttribute(gerritSpan, "http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("Gerrit span http.response.status_code = %d", got)
+	}
+
+	want := "00-" + testTraceID + "-" + gerritSpan.SpanContext.SpanID().String() + "-01"
+	if got := <-gerritTraceparent; got != want {
+		t.Errorf("traceparent sent to Gerrit = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gerrit-mcp/internal/logger"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	watchChangeToolName   = "watch_change"
+	unwatchChangeToolName = "unwatch_change"
+)
+
+// changeResourceURI matches the URIs of the change resources and of the
+// resources nested below them, e.g. their comments.
+var changeResourceURI = regexp.MustCompile(`^gerrit://([^/]+)/changes/(\d+)(/.*)?$`)
+
+func (s *Server) changeURI(number int) string {
+	baseURL := s.gerritClient.BaseURL()
+	return fmt.Sprintf("gerrit://%s/changes/%d", baseURL.Host, number)
+}
+
+// notifyResourceUpdated sends notifications/resources/updated to a session,
+// over its SSE or streamable HTTP stream.
+func (s *Server) notifyResourceUpdated(sessionID, uri string) {
+	err := s.mcpServer.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+	if errors.Is(err, server.ErrSessionNotFound) {
+		s.watcher.RemoveSession(sessionID)
+		return
+	}
+	if err != nil {
+		logger.Errorf("Failed to notify session %s of the update of %s: %v", sessionID, uri, err)
+		return
+	}
+	logger.Debugf("Notified session %s of the update of %s", sessionID, uri)
+}
+
+// addWatchHooks keeps the watches in sync with the resource unsubscriptions
+// and the sessions. Subscriptions go through subscriptionMiddleware.
+func (s *Server) addWatchHooks(hooks *server.Hooks) {
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.sessions.Store(session.SessionID(), true)
+	})
+	hooks.AddAfterUnsubscribe(func(ctx context.Context, id any, message *mcp.UnsubscribeRequest, result *mcp.EmptyResult) {
+		match := changeResourceURI.FindStringSubmatch(message.Params.URI)
+		session := server.ClientSessionFromContext(ctx)
+		if match == nil || session == nil {
+			return
+		}
+		number, _ := strconv.Atoi(match[2])
+		s.watcher.Unwatch(session.SessionID(), number, message.Params.URI)
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.sessions.Delete(session.SessionID())
+		s.watcher.RemoveSession(session.SessionID())
+	})
+}
+
+// subscriptionRejecter answers a resources/subscribe request with an error
+// the way its transport delivers responses.
+type subscriptionRejecter func(w http.ResponseWriter, sessionID string, response mcp.JSONRPCError)
+
+// writeJSONRPCError answers in the body of the request, as streamable HTTP
+// does.
+func writeJSONRPCError(w http.ResponseWriter, sessionID string, response mcp.JSONRPCError) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Errorf("Failed to write the error of session %s: %v", sessionID, err)
+	}
+}
+
+// subscriptionMiddleware watches the change of each resources/subscribe
+// request before passing it on, and rejects the request when the change
+// cannot be watched: mcp-go acknowledges subscriptions whatever its hooks do.
+func (s *Server) subscriptionMiddleware(next http.Handler, sessionID func(*http.Request) string, reject subscriptionRejecter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read the request: %v", err), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var message struct {
+			ID     mcp.RequestId `json:"id"`
+			Method string        `json:"method"`
+			Params struct {
+				URI string `json:"uri"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(body, &message) != nil || message.Method != string(mcp.MethodResourcesSubscribe) || message.Params.URI == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id := sessionID(r)
+		if err := s.subscribeResource(r.Context(), id, message.Params.URI); err != nil {
+			logger.FromContext(r.Context()).Infof("Rejected the subscription of session %s to %s: %v", id, message.Params.URI, err)
+			reject(w, id, mcp.NewJSONRPCError(message.ID, mcp.INVALID_PARAMS, err.Error(), nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// subscribeResource watches the change of a subscribed change resource URI.
+func (s *Server) subscribeResource(ctx context.Context, sessionID, uri string) error {
+	if _, ok := s.sessions.Load(sessionID); !ok {
+		return fmt.Errorf("unknown MCP session %q", sessionID)
+	}
+	match := changeResourceURI.FindStringSubmatch(uri)
+	if match == nil {
+		return fmt.Errorf("only change resources can be subscribed to")
+	}
+	baseURL := s.gerritClient.BaseURL()
+	if match[1] != baseURL.Host && match[1] != baseURL.Hostname() {
+		return fmt.Errorf("resource is not on the Gerrit instance %s", baseURL.Host)
+	}
+	if err := requireScopes(ctx, ScopeChangesRead); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(match[2])
+	if err != nil {
+		return err
+	}
+	found, err := s.changeByNumber(ctx, number)
+	if err != nil {
+		return err
+	}
+	return s.watcher.Watch(sessionID, found.Number, found.Updated.Time, uri)
+}
+
+// toolChange returns the change designated by the reviewURL or number
+// argument of a tool.
+func (s *Server) toolChange(ctx context.Context, args changeArgs) (*gerrit.ChangeInfo, error) {
+	if args.ReviewURL != "" {
+		return s.promptChange(ctx, args.ReviewURL)
+	}
+	if args.Number > 0 {
+		return s.changeByNumber(ctx, args.Number)
+	}
+	return nil, fmt.Errorf("either reviewURL or number must be provided")
+}
+
+func (s *Server) handleWatchChange(ctx context.Context, request mcp.CallToolRequest, args changeArgs) (*mcp.CallToolResult, error) {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return nil, fmt.Errorf("watching changes requires an MCP session")
+	}
+	found, err := s.toolChange(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	uri := s.changeURI(found.Number)
+	if err := s.watcher.Watch(session.SessionID(), found.Number, found.Updated.Time, uri); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf(
+		"Watching change %d (%s). New patch sets, comments and votes are notified as %s for %s.",
+		found.Number, found.Subject, mcp.MethodNotificationResourceUpdated, uri)), nil
+}
+
+func (s *Server) handleUnwatchChange(ctx context.Context, request mcp.CallToolRequest, args changeArgs) (*mcp.CallToolResult, error) {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return nil, fmt.Errorf("watching changes requires an MCP session")
+	}
+	number := args.Number
+	if number <= 0 {
+		found, err := s.toolChange(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		number = found.Number
+	}
+	if !s.watcher.Unwatch(session.SessionID(), number, "") {
+		return mcp.NewToolResultText(fmt.Sprintf("Change %d was not watched.", number)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Stopped watching change %d.", number)), nil
+}
@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestSubscribeResource(t *testing.T) {
+	gerritClient := newTestGerritClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/changes/" {
+			http.NotFound(w, r)
+			return
+		}
+		changes := []gerrit.ChangeInfo{}
+		if r.URL.Query().Get("q") == "change:42" {
+			changes = append(changes, gerrit.ChangeInfo{
+				Number: 42, Project: "chromium/src", Updated: gerrit.Timestamp{Time: time.Now().UTC().Truncate(time.Second)},
+			})
+		}
+		writeGerritJSON(t, w, changes)
+	}), nil)
+	s := NewServer(WithGerritClient(gerritClient))
+	mcpClient := newTestClient(t, serveTestServer(t, s), nil)
+
+	subscribe := func(uri string) error {
+		request := mcp.SubscribeRequest{}
+		request.Params.URI = uri
+		return mcpClient.Subscribe(context.Background(), request)
+	}
+	if err := subscribe("gerrit://127.0.0.1/changes/42"); err != nil {
+		t.Fatalf("Subscribe() to a change error = %v", err)
+	}
+	for uri, want := range map[string]string{
+		"gerrit://127.0.0.1/projects/chromium%2Fsrc": "only change resources",
+		"gerrit://example.com/changes/42":            "not on the Gerrit instance",
+		"gerrit://127.0.0.1/changes/43":              "change 43 not found",
+	} {
+		if err := subscribe(uri); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Subscribe(%s) error = %v, want %q", uri, err, want)
+		}
+	}
+	if got := s.watcher.Watched(mcpClient.GetSessionId()); !slices.Equal(got, []int{42}) {
+		t.Errorf("watched changes = %v, want [42]", got)
+	}
+}
+
+func TestWatchChangeRejectsShortReviewURL(t *testing.T) {
+	gerritClient := newTestGerritClient(t, http.NotFoundHandler(), nil)
+	s := NewServer(WithGerritClient(gerritClient))
+	mcpClient := newTestClient(t, serveTestServer(t, s), nil)
+
+	for _, name := range []string{watchChangeToolName, unwatchChangeToolName} {
+		for _, reviewURL := range []string{"http://127.0.0.1/q", "http://127.0.0.1/c/chromium/src/+"} {
+			request := mcp.CallToolRequest{}
+			request.Params.Name = name
+			request.Params.Arguments = map[string]any{"reviewURL": reviewURL}
+			result, err := mcpClient.CallTool(context.Background(), request)
+			if err == nil && result.IsError {
+				err = fmt.Errorf("%s", resultText(result))
+			}
+			if err == nil || !strings.Contains(err.Error(), "invalid review URL") {
+				t.Errorf("%s of %s error = %v, want an invalid review URL", name, reviewURL, err)
+			}
+		}
+	}
+	if got := s.watcher.Watched(mcpClient.GetSessionId()); len(got) != 0 {
+		t.Errorf("watched changes = %v", got)
+	}
+}